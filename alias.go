@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// commandAliases is implemented by parents storing the aliases of their sub
+// commands, like Root.
+type commandAliases interface {
+	aliasesOf(name string) []string
+}
+
+// subCommand is a sub command with its name and aliases
+type subCommand struct {
+	name    string
+	aliases []string
+	command Command
+}
+
+// names returns the name and the aliases of the sub command
+func (s subCommand) names() []string {
+	return append([]string{s.name}, s.aliases...)
+}
+
+// label returns the name of the sub command followed by its aliases
+func (s subCommand) label() string {
+	if len(s.aliases) == 0 {
+		return s.name
+	}
+	return s.name + " (" + strings.Join(s.aliases, ", ") + ")"
+}
+
+// subCommands returns the sub commands of parent ordered by name
+func subCommands(parent SubCommands) []subCommand {
+	var subs []subCommand
+	for name, c := range parent.SubCommands() {
+		s := subCommand{name: name, command: c}
+		if ca, ok := parent.(commandAliases); ok {
+			s.aliases = append(s.aliases, ca.aliasesOf(name)...)
+		}
+		if a, ok := c.(Aliaser); ok {
+			s.aliases = append(s.aliases, a.Aliases()...)
+		}
+		subs = append(subs, s)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].name < subs[j].name
+	})
+	return subs
+}
+
+// findSubCommand returns the sub command called name, by its name, one of
+// its aliases or, with PrefixMatching, an unambiguous prefix of them. Names
+// used by more than one sub command are ambiguous.
+func (cli *CLI) findSubCommand(subs []subCommand, name string) (*subCommand, error) {
+	found := matchSubCommands(subs, func(n string) bool {
+		return n == name
+	})
+	if len(found) == 0 && cli.PrefixMatching && !cli.completing && name != "" {
+		found = matchSubCommands(subs, func(n string) bool {
+			return strings.HasPrefix(n, name)
+		})
+	}
+	switch len(found) {
+	case 0:
+		return nil, nil
+	case 1:
+		return found[0], nil
+	}
+	names := make([]string, len(found))
+	for i, s := range found {
+		names[i] = s.name
+	}
+	return nil, errors.New(fmt.Sprintf("ambiguous command '%s', could be: %s", name, strings.Join(names, ", ")))
+}
+
+// matchSubCommands returns the sub commands having a name or an alias
+// matching match.
+func matchSubCommands(subs []subCommand, match func(name string) bool) []*subCommand {
+	var found []*subCommand
+	for i := range subs {
+		for _, n := range subs[i].names() {
+			if match(n) {
+				found = append(found, &subs[i])
+				break
+			}
+		}
+	}
+	return found
+}
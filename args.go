@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// argument is a struct field bound to a positional argument
+type argument struct {
+	name     string
+	value    reflect.Value
+	tag      reflect.StructTag
+	optional bool
+	variadic bool
+}
+
+// usage returns the argument as shown in the usage line
+func (a *argument) usage() string {
+	name := a.name
+	if a.variadic {
+		name += "..."
+	}
+	if a.optional {
+		return "[" + name + "]"
+	}
+	return "<" + name + ">"
+}
+
+// defineArguments returns the fields of st tagged with arg, in order
+func defineArguments(st reflect.Value) ([]*argument, error) {
+	st = reflect.Indirect(st)
+	if !st.IsValid() || st.Type().Kind() != reflect.Struct {
+		return nil, errors.New("non-nil pointer for struct expected")
+	}
+	var args []*argument
+	for i := 0; i < st.NumField(); i++ {
+		typ := st.Type().Field(i)
+		tag, ok := typ.Tag.Lookup("arg")
+		if !ok {
+			if typ.Anonymous && typ.Type.Kind() == reflect.Struct && typ.Tag.Get("flag") == "" {
+				embedded, err := defineArguments(st.Field(i))
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, embedded...)
+			}
+			continue
+		}
+		val := st.Field(i)
+		if !val.CanInterface() {
+			return nil, errors.New("field is unexported")
+		}
+		opts := strings.Split(tag, ",")
+		a := &argument{name: strings.TrimSpace(opts[0]), value: val, tag: typ.Tag}
+		for _, opt := range opts[1:] {
+			switch strings.TrimSpace(opt) {
+			case "optional":
+				a.optional = true
+			case "variadic":
+				a.variadic = true
+			default:
+				return nil, fmt.Errorf("argument %q has unknown option %q", a.name, opt)
+			}
+		}
+		if a.variadic && typ.Type.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("variadic argument %q must be a slice", a.name)
+		}
+		if newValue(val, typ.Tag) == nil {
+			return nil, fmt.Errorf("argument %q is of unsupported type", a.name)
+		}
+		args = append(args, a)
+	}
+	for i, a := range args {
+		if i > 0 && args[i-1].variadic {
+			return nil, fmt.Errorf("argument %q follows variadic argument %q", a.name, args[i-1].name)
+		}
+		if i > 0 && args[i-1].optional && !a.optional {
+			return nil, fmt.Errorf("required argument %q follows optional argument %q", a.name, args[i-1].name)
+		}
+	}
+	return args, nil
+}
+
+// bindArguments sets the arguments from values
+func bindArguments(args []*argument, values []string) error {
+	for _, a := range args {
+		if len(values) == 0 {
+			if a.optional {
+				return nil
+			}
+			return fmt.Errorf("missing argument %s", a.usage())
+		}
+		n := 1
+		if a.variadic {
+			n = len(values)
+		}
+		value := newValue(a.value, a.tag)
+		for _, v := range values[:n] {
+			if err := value.Set(v); err != nil {
+				return fmt.Errorf("invalid value %q for argument %s: %v", v, a.usage(), err)
+			}
+		}
+		values = values[n:]
+	}
+	if len(values) > 0 {
+		return fmt.Errorf("unexpected argument %q", values[0])
+	}
+	return nil
+}
+
+// usageLine returns the usage of c called by path, like
+// archiver queue info [options] <customer> [queue...]. It returns an empty
+// string if c has no positional arguments.
+func (cli *CLI) usageLine(c Command, path []string) string {
+	args, err := defineArguments(reflect.ValueOf(c))
+	if err != nil || len(args) == 0 {
+		return ""
+	}
+	parts := append([]string{cli.root.Name}, path...)
+	if hasFlags(reflect.TypeOf(c).Elem()) {
+		parts = append(parts, "[options]")
+	}
+	for _, a := range args {
+		parts = append(parts, a.usage())
+	}
+	return strings.Join(parts, " ")
+}
+
+// hasFlags reports whether the struct typ has fields tagged with flag
+func hasFlags(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("flag")
+		if tag != "" && tag != "-" && !strings.HasPrefix(tag, "-,") {
+			return true
+		}
+		if tag == "" && f.Type.Kind() == reflect.Struct && hasFlags(f.Type) {
+			return true
+		}
+	}
+	return false
+}
@@ -10,6 +10,120 @@
 //
 // Package understands all basic types supported by flag's package xxxVar functions:
 // int, int64, uint, uint64, float64, bool, string, time.Duration.
+// Slices of these types can be given by repeating the flag, or as one value
+// split by the separator set in the sep tag:
+//
+//     type Echo struct {
+//         Echoed []string `flag:"echoed, echo these strings" sep:","`
+//     }
+//
+// time.Time fields are parsed with the layout set in the layout tag, RFC3339
+// by default. Values like now, today, yesterday or -1d are also accepted:
+//
+//     type Echo struct {
+//         From time.Time `flag:"from, echo from this date" layout:"2006-01-02"`
+//     }
+//
+// Maps are filled from repeated key=value pairs:
+//
+//     type Echo struct {
+//         Labels map[string]string `flag:"label, add a label as key=value"`
+//     }
+//
+// Pointer fields stay nil unless the flag is given. CLI.IsSet reports whether
+// a flag was given, even with its zero value:
+//
+//     if cli.FromContext(ctx).IsSet(c, "echoed") {
+//     }
+//
+// The choices tag restricts the accepted values, they are shown in help and
+// offered by the shell completion:
+//
+//     type Echo struct {
+//         Format string `flag:"format, output format" choices:"csv,tsv,json"`
+//     }
+//
+// Flags with the required tag must be given on the command line:
+//
+//     type Echo struct {
+//         Echoed string `flag:"echoed, echo this string" required:"true"`
+//     }
+//
+// Flags not given on the command line are read from the environment variable
+// set in the env tag, or derived from CLI.EnvPrefix:
+//
+//     type Echo struct {
+//         Echoed string `flag:"echoed, echo this string" env:"ECHOED"`
+//     }
+//
+// Flags found neither on the command line nor in the environment are read
+// from CLI.ConfigFiles. Keys are the command path and the flag name, files
+// can be JSON or INI with TOML style values:
+//
+//     [queue.info]
+//     customer = ["first", "second"]
+//     base.v = true
+//
+// With CLI.DiscoverConfig the config files of the system, the user and the
+// project are read first, see cli.DiscoverConfigFiles. When config files are
+// used, the global --config flag given before the command name replaces all
+// of them. CLI.Origin tells where a value came from:
+//
+//     archiver --config ./staging.toml queue info
+//
+// command.NewConfig provides the config get, set, unset and list commands,
+// keys and values are checked against the flags of the commands:
+//
+//     archiver config set queue.info.customer first second
+//
+// Profiles are named groups of values in the config, they override the rest
+// of the config when selected with the global --profile flag, the
+// <NAME>_PROFILE environment variable or the profile key of the config.
+// command.NewProfile provides the profile list, use and show commands:
+//
+//     profile = "staging"
+//
+//     [profiles.staging.queue.info]
+//     customer = "test"
+//
+// Values of flags with the secret tag are hidden from help, config dumps and
+// cli.RedactedArgs. They can be read from a file as @path, or from
+// CLI.InputReader as -:
+//
+//     type Echo struct {
+//         Token string `flag:"token, api token" secret:"true"`
+//     }
+//
+//     archiver echo -token @/run/secrets/token
+//
+// Fields at their zero value receive the value of the default tag, parsed
+// like the flag. Commands can implement cli.Defaulter to compute defaults:
+//
+//     type Echo struct {
+//         Echoed string    `flag:"echoed, echo this string" default:"hello"`
+//         From   time.Time `flag:"from, echo from this date" default:"today"`
+//     }
+//
+// A one letter alias can follow the name of the flag. Set cli.GNUFlagSet as
+// flag parser to accept GNU style flags like -v, --verbose or -abc:
+//
+//     type Echo struct {
+//         Verbose bool `flag:"verbose|v, print more"`
+//     }
+//
+//     c.SetFlagSetFunc(func() cli.Flagger {
+//         return cli.NewGNUFlagSet()
+//     })
+//
+// Positional arguments are bound in order to fields tagged with arg, the
+// optional and variadic options set their arity. The usage line is generated
+// from them, like "archiver queue info <customer> [queue...]":
+//
+//     type Info struct {
+//         Customer string   `arg:"customer"`
+//         Queues   []string `arg:"queue,optional,variadic"`
+//     }
+//
 // Types implementing flag.Value interface are also supported.
 // (Useful package: https://github.com/sgreben/flagvar)
 //
@@ -49,6 +163,47 @@
 //         return nil
 //     }
 //
+// Errors returned from Run make CLI.Run return ExitError, errors implementing
+// cli.ExitCoder can choose their own exit status:
+//
+//     return cli.WithExitCode(errors.New("nothing to echo"), 3)
+//
+// Middlewares can wrap the run of every command, the running command and its
+// path can be retrieved from the context:
+//
+//     c.Use(func(next cli.RunFunc) cli.RunFunc {
+//         return func(ctx context.Context) error {
+//             start := time.Now()
+//             defer func() {
+//                 log.Println(cli.CommandPath(ctx), time.Since(start))
+//             }()
+//             return next(ctx)
+//         }
+//     })
+//
+// Flags of parent commands are persistent, they can be given before or after
+// the name of any sub command. A sub command declaring a flag with the same
+// name, or embedding the same struct under a prefix, receives the same value:
+//
+//     type Queue struct {
+//         base.Base
+//     }
+//
+//     type Info struct {
+//         base.Base `flag:"base"`
+//     }
+//
+//     archiver queue -v info
+//     archiver queue info -v
+//     archiver queue info -base.v
+//
+// Commands on the resolved path, parents included, can implement
+// cli.PreRunner and cli.PostRunner to set up and tear down shared state:
+//
+//     func (c *Queue) PreRun(ctx context.Context) error {
+//         return c.connect()
+//     }
+//
 // We can set default command to run
 //
 //     c.SetDefault("echo")
@@ -56,14 +211,18 @@
 // After all of this, we can run them like this:
 //
 //     func main() {
-//         c := cli.New("archiver", "1.0.0")
-//         cli.RootCommand().Authors = []string{"authors goes here"}
-//         cli.RootCommand().Description = `Lorem Ipsum is simply dummy text of the printing and typesetting industry.
+//         root := cli.NewRoot("archiver", "1.0.0")
+//         root.Authors = []string{"authors goes here"}
+//         root.Description = `Lorem Ipsum is simply dummy text of the printing and typesetting industry.
 // Lorem Ipsum has been the industry's standard dummy text ever since the 1500s`
 //
-//         cli.RootCommand().AddCommand("echo", &Echo{})
-//         c.Run(context.Background(), os.Args)
+//         root.AddCommand("echo", &Echo{})
+//         c := cli.NewWithRoot(root)
+//         os.Exit(c.Run(context.Background(), os.Args))
 //     }
+//
+// cli.New uses the package level root returned by cli.RootCommand, which
+// commands can register themselves into from init functions.
 package cli
 
 import (
@@ -84,7 +243,8 @@ import (
 const (
 	completeLine        = "COMP_LINE"
 	completePoint       = "COMP_POINT"
-	defaultHelpTemplate = `{{.Help}}
+	defaultHelpTemplate = `{{with .Usage}}Usage: {{.}}
+{{end}}{{.Help}}
 {{with $flags := flagSet .Command}}{{if ne $flags ""}}
 Options:
 {{$flags}}{{- end }}{{end}}{{if gt (len .SubCommands) 0}}
@@ -103,33 +263,121 @@ type CLI struct {
 	HelpWriter io.Writer
 	// ErrorWriter used to output errors when a command can not be run.
 	ErrorWriter io.Writer
+	// InputReader used by commands to read their input. Defaults to os.Stdin.
+	InputReader io.Reader
+	// LookupEnv used to read environment variables. Defaults to os.LookupEnv.
+	LookupEnv func(key string) (string, bool)
 	// AutoComplete used to handle autocomplete request from bash or zsh.
-	AutoComplete     bool
+	AutoComplete bool
+	// EnvPrefix enables reading every flag from an environment variable
+	// named after the prefix, the command path and the flag name, like
+	// PREFIX_QUEUE_INFO_CUSTOMER.
+	EnvPrefix string
+	// ConfigFiles are read before parsing to set the flags not given on the
+	// command line or in the environment. Later files override earlier
+	// ones, missing files are skipped.
+	ConfigFiles []string
+	// DiscoverConfig reads the config files of the system, the user and the
+	// project before ConfigFiles, see DiscoverConfigFiles.
+	DiscoverConfig bool
+	// SystemConfigDir holds the system config of DiscoverConfig, in
+	// <SystemConfigDir>/<name>/config. Defaults to /etc, an empty string
+	// disables it.
+	SystemConfigDir string
+	// Getwd returns the directory the project config of DiscoverConfig is
+	// searched from. Defaults to os.Getwd, nil disables it.
+	Getwd func() (string, error)
+	// ConfigFlag is the name of the global flag replacing the config files
+	// by the given one, like --config path. Defaults to config, set to an
+	// empty string to disable it.
+	ConfigFlag string
+	// ProfileFlag is the name of the global flag selecting a profile, like
+	// --profile staging. Defaults to profile, set to an empty string to
+	// disable it.
+	ProfileFlag string
+	// PrefixMatching allows to call commands by an unambiguous prefix of
+	// their name or aliases.
+	PrefixMatching bool
+	// Signals cancel the context of the running command, defaults to
+	// SIGINT and SIGTERM. Set to an empty slice to disable signal handling.
+	Signals []os.Signal
+	// GracePeriod is the time a command has to return after its context was
+	// canceled by a signal, zero means it waits until a second signal.
+	GracePeriod time.Duration
+	// Exit used to force exit when the grace period is over, or a second
+	// signal is received. Defaults to os.Exit.
+	Exit             func(code int)
 	root             *Root
 	defaultCommand   string
 	flagSet          Flagger
+	newFlagSet       func() Flagger
+	fixedFlagSet     bool
+	fields           map[string]*field
 	flagSetOut       bytes.Buffer
 	template         string
+	templateFuncs    template.FuncMap
 	lastCommandsName []string
+	commandPath      []string
+	commands         []Command
+	completing       bool
+	middlewares      []Middleware
+	config           *Config
+	configFile       string
+	profileFlag      string
+	profile          string
+	setFlags         *flagRegistry
+	initialValues    map[Command]reflect.Value
 }
 
-// New returns a new CLI struct
+// New returns a new CLI struct running the commands of the default Root
 func New(name string, version string) *CLI {
-	cli := &CLI{
-		root:         RootCommand(),
-		HelpWriter:   os.Stdout,
-		AutoComplete: true,
-		ErrorWriter:  os.Stderr,
-		flagSet: &flag.FlagSet{
-			Usage: func() {},
-		},
-		template: defaultHelpTemplate,
+	root := RootCommand()
+	root.Name = name
+	root.Version = version
+
+	return NewWithRoot(root)
+}
+
+// NewWithRoot returns a new CLI struct running the commands of root
+func NewWithRoot(root *Root) *CLI {
+	return &CLI{
+		root:            root,
+		HelpWriter:      os.Stdout,
+		AutoComplete:    true,
+		ErrorWriter:     os.Stderr,
+		InputReader:     os.Stdin,
+		LookupEnv:       os.LookupEnv,
+		ConfigFlag:      "config",
+		ProfileFlag:     "profile",
+		SystemConfigDir: "/etc",
+		Getwd:           os.Getwd,
+		newFlagSet:      newFlagSet,
+		template:        defaultHelpTemplate,
 	}
-	cli.flagSet.SetOutput(&cli.flagSetOut)
-	cli.root.Name = name
-	cli.root.Version = version
+}
+
+func newFlagSet() Flagger {
+	return &flag.FlagSet{
+		Usage: func() {},
+	}
+}
 
-	return cli
+// Root returns the root command of the CLI
+func (cli *CLI) Root() *Root {
+	return cli.root
+}
+
+// Resolved returns the path and the command resolved by the last Run
+func (cli *CLI) Resolved() ([]string, Command) {
+	if len(cli.commands) == 0 {
+		return nil, nil
+	}
+	return append([]string(nil), cli.commandPath...), cli.commands[len(cli.commands)-1]
+}
+
+// Config returns the config read by the last Run
+func (cli *CLI) Config() *Config {
+	return cli.config
 }
 
 // SetDefault sets default command
@@ -137,134 +385,264 @@ func (cli *CLI) SetDefault(command string) {
 	cli.defaultCommand = command
 }
 
-// Run parses the arguments and runs the applicable command
-func (cli *CLI) Run(ctx context.Context, args []string) {
+// Run parses the arguments and runs the applicable command. It returns the
+// exit status for the process: ExitOK on success, ExitUsage when the command
+// line could not be parsed and ExitError when the command failed, unless the
+// returned error implements ExitCoder.
+//
+// The context given to the command is canceled when one of the Signals is
+// received, in this case Run returns 128 plus the signal number.
+func (cli *CLI) Run(ctx context.Context, args []string) int {
+	cli.flagSet = cli.newFlagSet()
+	if cli.flagSet == nil {
+		cli.ErrorWriter.Write([]byte("the flag set given to SetFlagSet was already used, use SetFlagSetFunc to run more than once\n"))
+		return ExitError
+	}
+	cli.flagSetOut.Reset()
+	cli.flagSet.SetOutput(&cli.flagSetOut)
 	doComplete := false
 	if line, ok := cli.isCompleteStarted(); ok {
 		if !cli.AutoComplete {
-			return
+			return ExitOK
 		}
 		args = strings.Split(line, " ")
 		doComplete = true
 	}
+	cli.completing = doComplete
+	invoked := args
+	args, err := cli.extractGlobalFlags(args)
+	if err == nil {
+		err = cli.loadConfig()
+	}
+	if err != nil {
+		if doComplete {
+			return ExitOK
+		}
+		cli.help(cli.root, newUsageError(err))
+		return ExitUsage
+	}
 	if len(args) == 1 {
 		args = append(args, cli.defaultCommand)
 	}
 	if strings.HasPrefix(args[1], "-") {
 		args = append([]string{args[0], cli.defaultCommand}, args[1:]...)
 	}
+	cli.commandPath = []string{}
+	cli.commands = []Command{}
+	cli.fields = make(map[string]*field)
+	cli.setFlags = newFlagRegistry()
 	c, err := cli.getSubCommand(cli.root, args[1:])
 	if doComplete {
-		lastArg := strings.TrimLeft(args[len(args)-1], "-")
-		if len(cli.lastCommandsName) > 0 {
-			for _, name := range cli.lastCommandsName {
-				if strings.HasPrefix(name, lastArg) {
-					cli.HelpWriter.Write([]byte(name + "\n"))
-				}
-			}
-		} else {
-			cli.flagSet.VisitAll(func(f *flag.Flag) {
-				if strings.HasPrefix(f.Name, lastArg) {
-					cli.HelpWriter.Write([]byte("-" + f.Name + "\n"))
-				}
-			})
-		}
-		return
+		cli.complete(args)
+		return ExitOK
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		cli.help(c, nil)
+		return ExitOK
 	}
 	if err != nil {
+		err = newUsageError(err)
 		cli.help(c, err)
-		return
+		return exitCode(err)
 	}
 	if c == nil {
 		cli.help(cli.root, nil)
-		return
-
+		return ExitUsage
+	}
+	ctx = withInvocation(ctx, &invocation{
+		cli:     cli,
+		path:    cli.commandPath,
+		command: c,
+		args:    cli.redactArgs(invoked),
+	})
+	sig, err := cli.runWithSignals(ctx, cli.chain(cli.lifecycle(cli.commands)))
+	if sig != nil {
+		if err != nil {
+			cli.ErrorWriter.Write([]byte(err.Error() + "\n"))
+		}
+		var ec ExitCoder
+		if errors.As(err, &ec) {
+			return ec.ExitCode()
+		}
+		return signalExitCode(sig)
 	}
-	if err := c.Run(ctx); err != nil {
+	if err != nil {
 		cli.help(c, err)
+		return exitCode(err)
 	}
+	return ExitOK
 }
 
-// SetTemplate set a new template for commands
+// SetTemplate set a new template for commands. The template is executed with
+// the command, and its sub commands in SubCommands, see defaultHelpTemplate.
 func (cli *CLI) SetTemplate(template string) {
 	cli.template = template
 }
 
-// SetFlagSet set an different flag parser
+// AddTemplateFuncs adds functions to the help templates, they can override
+// the default replace and flagSet functions.
+func (cli *CLI) AddTemplateFuncs(funcs template.FuncMap) {
+	if cli.templateFuncs == nil {
+		cli.templateFuncs = make(template.FuncMap)
+	}
+	for name, fn := range funcs {
+		cli.templateFuncs[name] = fn
+	}
+}
+
+// SetFlagSet set an different flag parser. A flag set can be parsed only
+// once, further calls of Run fail with an error.
+//
+// Deprecated: use SetFlagSetFunc, which gives a new flag set to every Run.
 func (cli *CLI) SetFlagSet(flagSet Flagger) {
-	cli.flagSet = flagSet
-	cli.flagSet.SetOutput(&cli.flagSetOut)
+	cli.fixedFlagSet = true
+	cli.newFlagSet = func() Flagger {
+		fs := flagSet
+		flagSet = nil
+		return fs
+	}
+}
+
+// FlagSetReusable reports whether every Run gets a new flag set, so the CLI
+// can be run more than once. It is false after SetFlagSet.
+func (cli *CLI) FlagSetReusable() bool {
+	return !cli.fixedFlagSet
+}
+
+// SetFlagSetFunc set a function returning a new flag parser for every Run
+func (cli *CLI) SetFlagSetFunc(fn func() Flagger) {
+	cli.fixedFlagSet = false
+	cli.newFlagSet = fn
 }
 
 func (cli *CLI) getSubCommand(command SubCommands, args []string) (Command, error) {
+	subs := subCommands(command)
 	cli.lastCommandsName = []string{}
-	for name, c := range command.SubCommands() {
-		cli.lastCommandsName = append(cli.lastCommandsName, name)
-		if name == args[0] {
-			if err := cli.getFlagSet(c); err != nil {
-				return c, err
-			}
-			if subC, ok := c.(SubCommands); ok {
-				if len(args) <= 1 {
-					return c, errors.New("missing sub command")
-				}
-				subC, err := cli.getSubCommand(subC, args[1:])
-				if subC != nil {
-					cli.lastCommandsName = []string{}
-				}
-				if err != nil {
-					if subC == nil {
-						subC = c
-					}
-					return subC, err
-				}
-				if subC == nil {
-					return c, errors.New("wrong sub command")
-				}
-				return subC, nil
-			}
-			var parseArg []string
-			if len(args) > 1 {
-				parseArg = args[1:]
-			}
-			if err := cli.flagSet.Parse(parseArg); err != nil {
+	for _, s := range subs {
+		cli.lastCommandsName = append(cli.lastCommandsName, s.names()...)
+	}
+	sub, err := cli.findSubCommand(subs, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		if args[0] == "" {
+			return nil, nil
+		}
+		return nil, unknownCommandError(args[0], cli.lastCommandsName)
+	}
+	name, c := sub.name, sub.command
+	cli.commandPath = append(cli.commandPath, name)
+	cli.commands = append(cli.commands, c)
+	if err := cli.getFlagSet(c); err != nil {
+		return c, err
+	}
+	if subC, ok := c.(SubCommands); ok {
+		if err := cli.parseFlags(args[1:]); err != nil {
+			return c, err
+		}
+		cli.syncFields()
+		rest := cli.flagSet.Args()
+		if len(rest) == 0 {
+			return c, errors.New("missing sub command")
+		}
+		if p, ok := c.(ParseHelper); ok {
+			if err := p.Parse(rest); err != nil {
 				return c, err
 			}
-			if p, ok := c.(ParseHelper); ok {
-				if err := p.Parse(cli.flagSet.Args()); err != nil {
-					return c, err
-				}
+		}
+		subC, err := cli.getSubCommand(subC, rest)
+		if subC != nil {
+			cli.lastCommandsName = []string{}
+		}
+		if err != nil {
+			if subC == nil {
+				subC = c
 			}
-			return c, nil
+			return subC, err
+		}
+		if subC == nil {
+			return c, errors.New("wrong sub command")
+		}
+		return subC, nil
+	}
+	var parseArg []string
+	if len(args) > 1 {
+		parseArg = args[1:]
+	}
+	if err := cli.parseFlags(parseArg); err != nil {
+		return c, err
+	}
+	cli.syncFields()
+	if err := cli.checkVisit(); err != nil {
+		return c, err
+	}
+	if err := cli.setFromSources(); err != nil {
+		return c, err
+	}
+	if err := cli.checkRequired(); err != nil {
+		return c, err
+	}
+	positionals, err := defineArguments(reflect.ValueOf(c))
+	if err != nil {
+		return c, err
+	}
+	if len(positionals) > 0 {
+		if err := bindArguments(positionals, cli.flagSet.Args()); err != nil {
+			return c, err
 		}
 	}
-	return nil, nil
+	if p, ok := c.(ParseHelper); ok {
+		if err := p.Parse(cli.flagSet.Args()); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
 }
 
 func (cli *CLI) help(c Command, err error) {
+	if c == nil {
+		c = cli.root
+	}
 	output := cli.HelpWriter
 	if err != nil {
 		output = cli.ErrorWriter
 		output.Write([]byte(err.Error() + "\n\n"))
 	}
+	text := cli.template
+	if ht, ok := c.(HelpTemplater); ok {
+		text = ht.HelpTemplate()
+	}
+	paths := map[Command][]string{c: cli.pathOf(c)}
 	t, err := template.New("root").Funcs(template.FuncMap{
 		"replace": strings.Replace,
 		"flagSet": func(c Command) string {
-			fs := &flag.FlagSet{
-				Usage: func() {},
-			}
+			fs := cli.helpFlagSet()
 			var out bytes.Buffer
 			fs.SetOutput(&out)
 			st := reflect.ValueOf(c)
-			if st.Kind() != reflect.Ptr {
-				return err.Error()
+			if st.Kind() != reflect.Ptr || st.IsNil() {
+				return "pointer expected"
+			}
+			// defaults are set on a copy to keep the values of c
+			cp := reflect.New(st.Elem().Type())
+			cp.Elem().Set(st.Elem())
+			if d, ok := cp.Interface().(Defaulter); ok {
+				d.SetDefaults()
 			}
-			if err := cli.defineFlagSet(fs, st, ""); err != nil {
+			st = cp
+			pc := pathCommand{Command: c, path: paths[c]}
+			fields := make(map[string]*field)
+			if err := cli.defineFlagSet(fs, fields, pc, st, ""); err != nil {
 				return err.Error()
 			}
-			fs.PrintDefaults()
+			if std, ok := fs.(*flag.FlagSet); ok {
+				printDefaults(&out, std.VisitAll, shortLabel(fields))
+			} else if p, ok := fs.(interface{ PrintDefaults() }); ok {
+				p.PrintDefaults()
+			}
 			return out.String()
-		}}).Parse(defaultHelpTemplate)
+		}}).Funcs(cli.templateFuncs).Parse(text)
 	if err != nil {
 		cli.ErrorWriter.Write([]byte(fmt.Sprintf(
 			"Internal error! Failed to parse command help template: %s\n", err)))
@@ -272,30 +650,58 @@ func (cli *CLI) help(c Command, err error) {
 	}
 	s := struct {
 		Command
+		Usage       string
 		SubCommands map[string]interface{}
 	}{
 		Command:     c,
+		Usage:       cli.usageLine(c, paths[c]),
 		SubCommands: make(map[string]interface{}),
 	}
 	if subCs, ok := c.(SubCommands); ok {
 		longest := 0
-		subC := subCs.SubCommands()
-		for k, _ := range subC {
-			if v := len(k); v > longest {
+		subs := subCommands(subCs)
+		for _, sub := range subs {
+			if v := len(sub.label()); v > longest {
 				longest = v
 			}
 		}
-		for name, command := range subC {
-			c := command
-			s.SubCommands[name] = map[string]interface{}{
+		parentPath := paths[c]
+		for _, sub := range subs {
+			c := sub.command
+			paths[c] = append(append([]string(nil), parentPath...), sub.name)
+			label := sub.label()
+			s.SubCommands[sub.name] = map[string]interface{}{
 				"Command":     c,
 				"Synopsis":    c.Synopsis(),
 				"Help":        c.Help(),
-				"NameAligned": name + strings.Repeat(" ", longest-len(name)),
+				"Aliases":     sub.aliases,
+				"NameAligned": label + strings.Repeat(" ", longest-len(label)),
 			}
 		}
 	}
-	t.Execute(output, s)
+	if err := t.Execute(output, s); err != nil {
+		cli.ErrorWriter.Write([]byte(fmt.Sprintf(
+			"Internal error! Failed to execute command help template: %s\n", err)))
+	}
+}
+
+// helpFlagSet returns an empty flag set printing the flags in the style of
+// the one used for parsing.
+func (cli *CLI) helpFlagSet() Flagger {
+	if _, ok := cli.flagSet.(*GNUFlagSet); ok {
+		return NewGNUFlagSet()
+	}
+	return &flag.FlagSet{
+		Usage: func() {},
+	}
+}
+
+// flagArg returns name as it is given on the command line
+func (cli *CLI) flagArg(name string) string {
+	if _, ok := cli.flagSet.(*GNUFlagSet); ok && len(name) > 1 {
+		return "--" + name
+	}
+	return "-" + name
 }
 
 func (cli *CLI) getFlagSet(c Command) error {
@@ -303,25 +709,32 @@ func (cli *CLI) getFlagSet(c Command) error {
 	if st.Kind() != reflect.Ptr {
 		return errors.New("pointer expected")
 	}
-	if err := cli.defineFlagSet(cli.flagSet, st, ""); err != nil {
+	cli.restoreFields(c, st)
+	if d, ok := c.(Defaulter); ok {
+		d.SetDefaults()
+	}
+	pc := pathCommand{Command: c, path: append([]string(nil), cli.commandPath...)}
+	if err := cli.defineFlagSet(cli.flagSet, cli.fields, pc, st, ""); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (cli *CLI) defineFlagSet(fs Flagger, st reflect.Value, subName string) error {
+// defineFlagSet registers the tagged fields of st into fs. A flag declared
+// again by an other command is not redefined, the field is linked to the
+// already registered one instead.
+func (cli *CLI) defineFlagSet(fs Flagger, fields map[string]*field, c pathCommand, st reflect.Value, subName string) error {
 	st = reflect.Indirect(st)
 	if !st.IsValid() || st.Type().Kind() != reflect.Struct {
 		return errors.New("non-nil pointer for struct expected")
 	}
-	flagValueType := reflect.TypeOf((*flag.Value)(nil)).Elem()
 	for i := 0; i < st.NumField(); i++ {
 		typ := st.Type().Field(i)
 		var name, usage string
 		tag := typ.Tag.Get("flag")
 		if tag == "" {
 			if typ.Type.Kind() == reflect.Struct {
-				if err := cli.defineFlagSet(fs, st.Field(i), ""); err != nil {
+				if err := cli.defineFlagSet(fs, fields, c, st.Field(i), ""); err != nil {
 					return err
 				}
 				continue
@@ -345,51 +758,181 @@ func (cli *CLI) defineFlagSet(fs Flagger, st reflect.Value, subName string) erro
 		if name == "-" {
 			continue
 		}
+		var short string
+		if names := strings.SplitN(name, "|", 2); len(names) == 2 {
+			name, short = names[0], strings.TrimSpace(names[1])
+		}
 		if subName != "" {
 			name = subName + "." + name
 		}
 		addr := val.Addr()
-		if addr.Type().Implements(flagValueType) {
-			fs.Var(addr.Interface().(flag.Value), name, usage)
-			continue
-		} else if typ.Type.Kind() == reflect.Struct {
-			if err := cli.defineFlagSet(fs, st.Field(i), name); err != nil {
+		isValue := addr.Type().Implements(flagValueType)
+		if !isValue && typ.Type.Kind() == reflect.Struct && typ.Type != timeType {
+			if err := cli.defineFlagSet(fs, fields, c, st.Field(i), name); err != nil {
 				return err
 			}
 			continue
 		}
-		switch d := val.Interface().(type) {
-		case int:
-			fs.IntVar(addr.Interface().(*int), name, d, usage)
-		case int64:
-			fs.Int64Var(addr.Interface().(*int64), name, d, usage)
-		case uint:
-			fs.UintVar(addr.Interface().(*uint), name, d, usage)
-		case uint64:
-			fs.Uint64Var(addr.Interface().(*uint64), name, d, usage)
-		case float64:
-			fs.Float64Var(addr.Interface().(*float64), name, d, usage)
-		case bool:
-			fs.BoolVar(addr.Interface().(*bool), name, d, usage)
-		case string:
-			fs.StringVar(addr.Interface().(*string), name, d, usage)
-		case time.Duration:
-			fs.DurationVar(addr.Interface().(*time.Duration), name, d, usage)
-		default:
-			return errors.New(fmt.Sprintf("field with flag tag value %q is of unsupported type", name))
+		if f, ok := fields[name]; ok {
+			if f.value.Type() != val.Type() {
+				return fmt.Errorf("flag %q is redefined with a different type", name)
+			}
+			f.links = append(f.links, &field{name: name, value: val, command: c.Command, path: c.path, source: structField{typ: st.Type(), index: i}})
+			continue
+		}
+		source := structField{typ: st.Type(), index: i}
+		env := typ.Tag.Get("env")
+		if env == "" && cli.EnvPrefix != "" {
+			env = envName(cli.EnvPrefix, c.path, name)
+		}
+		if owner := sameField(fields, source, c.Command); owner != nil {
+			// the field of an embedded struct of a parent command is
+			// declared under another name, like v and base.v, its short
+			// name is already the one of owner
+			owner.links = append(owner.links, &field{name: name, value: val, command: c.Command, path: c.path, env: env, source: source})
+			defineAlias(fs, owner.name, name, usage)
+			continue
+		}
+		if other := lookupField(fields, name); other != nil {
+			return fmt.Errorf("flag %q is already used as short name of %q", name, other.name)
+		}
+		if short != "" {
+			if other := lookupField(fields, short); other != nil {
+				return fmt.Errorf("short flag %q of %q is already used by %q", short, name, other.name)
+			}
+		}
+		f := &field{name: name, short: short, value: val, command: c.Command, path: c.path, env: env, source: source}
+		if tag := typ.Tag.Get("choices"); tag != "" {
+			f.choices = strings.Split(tag, ",")
+		}
+		f.required, _ = strconv.ParseBool(typ.Tag.Get("required"))
+		f.secret, _ = strconv.ParseBool(typ.Tag.Get("secret"))
+		fields[name] = f
+		if def, ok := typ.Tag.Lookup("default"); ok && val.IsZero() {
+			if err := setDefault(val, typ.Tag, def); err != nil {
+				return fmt.Errorf("invalid default value %q for flag -%s: %v", def, name, err)
+			}
+		}
+		usage = f.usage(usage, typ.Tag)
+		if isValue || f.choices != nil || f.secret || !defineBasic(fs, val, name, usage) {
+			value := newValue(val, typ.Tag)
+			if value == nil {
+				return errors.New(fmt.Sprintf("field with flag tag value %q is of unsupported type", name))
+			}
+			if f.choices != nil {
+				value = &choiceValue{Value: value, choices: f.choices, sep: typ.Tag.Get("sep")}
+			}
+			if f.secret {
+				value = &secretValue{Value: value, input: cli.InputReader}
+			}
+			fs.Var(value, name, usage)
+		}
+		if short != "" {
+			defineShort(fs, name, short, usage)
 		}
 	}
 	return nil
 }
 
+// shortLabel returns the label of flags for printDefaults, flags with a
+// short alias are printed once as "-n, -name", the alias is skipped.
+func shortLabel(fields map[string]*field) func(name string) string {
+	return func(name string) string {
+		f := lookupField(fields, name)
+		switch {
+		case f == nil:
+			return "  -" + name
+		case f.name != name:
+			return ""
+		case f.short != "":
+			return fmt.Sprintf("  -%s, -%s", f.short, f.name)
+		}
+		return "  -" + name
+	}
+}
+
+// defineShort registers short as an alias of the flag called name. Flag sets
+// without support for aliases get a second flag sharing the value.
+func defineShort(fs Flagger, name string, short string, usage string) {
+	if a, ok := fs.(interface {
+		Alias(short string, name string)
+	}); ok {
+		a.Alias(short, name)
+		return
+	}
+	defineAlias(fs, name, short, usage)
+}
+
+// defineAlias defines a flag called alias sharing the value of the flag
+// called name.
+func defineAlias(fs Flagger, name string, alias string, usage string) {
+	var value flag.Value
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == name {
+			value = f.Value
+		}
+	})
+	if value != nil {
+		fs.Var(value, alias, usage)
+	}
+}
+
+// defineBasic registers val with the typed methods of fs if it is one of the
+// types the flag package supports.
+func defineBasic(fs Flagger, val reflect.Value, name string, usage string) bool {
+	addr := val.Addr()
+	switch d := val.Interface().(type) {
+	case int:
+		fs.IntVar(addr.Interface().(*int), name, d, usage)
+	case int64:
+		fs.Int64Var(addr.Interface().(*int64), name, d, usage)
+	case uint:
+		fs.UintVar(addr.Interface().(*uint), name, d, usage)
+	case uint64:
+		fs.Uint64Var(addr.Interface().(*uint64), name, d, usage)
+	case float64:
+		fs.Float64Var(addr.Interface().(*float64), name, d, usage)
+	case bool:
+		fs.BoolVar(addr.Interface().(*bool), name, d, usage)
+	case string:
+		fs.StringVar(addr.Interface().(*string), name, d, usage)
+	case time.Duration:
+		fs.DurationVar(addr.Interface().(*time.Duration), name, d, usage)
+	default:
+		return false
+	}
+	return true
+}
+
 func (cli *CLI) isCompleteStarted() (string, bool) {
-	line := os.Getenv(completeLine)
+	line := cli.getenv(completeLine)
 	if line == "" {
 		return "", false
 	}
-	point, err := strconv.Atoi(os.Getenv(completePoint))
+	point, err := strconv.Atoi(cli.getenv(completePoint))
 	if err == nil && point > 0 && point < len(line) {
 		line = line[:point]
 	}
 	return line, true
 }
+
+func (cli *CLI) pathOf(c Command) []string {
+	for i, cmd := range cli.commands {
+		if cmd == c {
+			return cli.commandPath[:i+1]
+		}
+	}
+	return nil
+}
+
+func (cli *CLI) getenv(key string) string {
+	v, _ := cli.lookupEnv(key)
+	return v
+}
+
+func (cli *CLI) lookupEnv(key string) (string, bool) {
+	if cli.LookupEnv == nil {
+		return os.LookupEnv(key)
+	}
+	return cli.LookupEnv(key)
+}
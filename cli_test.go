@@ -0,0 +1,1244 @@
+package cli_test
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/Ak-Army/cli"
+	"github.com/Ak-Army/cli/clitest"
+	"github.com/Ak-Army/cli/command"
+)
+
+type Base struct {
+	Verbose bool `flag:"v, print more"`
+}
+
+type queue struct {
+	Base
+	subCommands map[string]cli.Command
+}
+
+func (q *queue) Help() string     { return "Interact with the queue service" }
+func (q *queue) Synopsis() string { return "Interact with the queue service" }
+func (q *queue) Run(ctx context.Context) error {
+	return errors.New("select a sub command")
+}
+
+func (q *queue) SubCommands() map[string]cli.Command {
+	if q.subCommands == nil {
+		q.subCommands = map[string]cli.Command{
+			"info": &info{},
+			"fail": &fail{},
+		}
+	}
+	return q.subCommands
+}
+
+type info struct {
+	Base      `flag:"base"`
+	Customer  []string `flag:"customer|c, customer to show"`
+	ProjectID int64    `flag:"projectId, project to show" required:"true"`
+	Format    string   `flag:"format, output format" choices:"csv,json" default:"csv"`
+	Token     string   `flag:"token, api token" secret:"true"`
+	Queues    []string `arg:"queue,optional,variadic"`
+}
+
+func (i *info) Help() string     { return "Print queue info" }
+func (i *info) Synopsis() string { return "Print queue info" }
+func (i *info) Run(ctx context.Context) error {
+	c := cli.FromContext(ctx)
+	fmt.Fprintf(c.HelpWriter, "customer=%v projectId=%d format=%s verbose=%v token=%v queues=%v\n",
+		i.Customer, i.ProjectID, i.Format, i.Verbose, i.Token != "", i.Queues)
+	fmt.Fprintf(c.HelpWriter, "args=%q\n", cli.RedactedArgs(ctx))
+	for _, name := range c.FlagsSet(i) {
+		fmt.Fprintf(c.HelpWriter, "%s: %s\n", name, c.Origin(i, name))
+	}
+	return nil
+}
+
+type fail struct{}
+
+func (f *fail) Help() string     { return "Fail" }
+func (f *fail) Synopsis() string { return "Fail with exit code 3" }
+func (f *fail) Run(ctx context.Context) error {
+	return cli.WithExitCode(errors.New("failed"), 3)
+}
+
+func newHarness() *clitest.Harness {
+	root := cli.NewRoot("app", "1.0.0")
+	root.AddCommand("queue", &queue{}, "q")
+	c := cli.NewWithRoot(root)
+	c.EnvPrefix = "APP"
+	return clitest.New(c)
+}
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+	}{
+		{name: "no_command", args: []string{}},
+		{name: "queue_help", args: []string{"queue", "-h"}},
+		{name: "info_help", args: []string{"queue", "info", "-h"}},
+		{name: "info", args: []string{"queue", "info", "-projectId", "1", "-c", "a", "-customer", "b", "q1", "q2"}},
+		{name: "persistent_flag", args: []string{"q", "-v", "info", "-projectId", "1"}},
+		{name: "env", args: []string{"queue", "info"}, env: map[string]string{"APP_QUEUE_INFO_PROJECTID": "2", "APP_QUEUE_INFO_FORMAT": "json"}},
+		{name: "missing_required", args: []string{"queue", "info"}},
+		{name: "invalid_choice", args: []string{"queue", "info", "-projectId", "1", "-format", "xml"}},
+		{name: "secret", args: []string{"queue", "info", "-projectId", "1", "-token", "s3cret"}},
+		{name: "unknown_command", args: []string{"queu"}},
+		{name: "unknown_flag", args: []string{"queue", "info", "-projectid", "1"}},
+		{name: "exit_code", args: []string{"queue", "fail"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness()
+			for k, v := range tt.env {
+				h.Env[k] = v
+			}
+			h.Run(tt.args...).AssertGolden(t, tt.name)
+		})
+	}
+}
+
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{name: "complete_commands", line: "app "},
+		{name: "complete_sub_commands", line: "app queue "},
+		{name: "complete_flags", line: "app queue info -"},
+		{name: "complete_choices", line: "app queue info -format "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newHarness().Complete(tt.line).AssertGolden(t, tt.name)
+		})
+	}
+}
+
+func TestRunTwice(t *testing.T) {
+	h := newHarness()
+	r := h.Run("queue", "-v", "info", "-projectId", "1", "-c", "a", "-format", "json", "q1")
+	if r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	first := r.Command.(*info)
+	r = h.Run("queue", "info", "-projectId", "2")
+	if r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	i := r.Command.(*info)
+	if i != first {
+		t.Fatal("expected the same command in both runs")
+	}
+	if i.ProjectID != 2 || i.Customer != nil || i.Format != "csv" || i.Verbose || i.Queues != nil {
+		t.Errorf("values of the previous run kept: %+v", i)
+	}
+	if got := h.CLI.FlagsSet(i); strings.Join(got, " ") != "projectId" {
+		t.Errorf("got flags set %q", got)
+	}
+	r = h.Run("queue", "info")
+	if r.Status != cli.ExitUsage {
+		t.Fatalf("got status %d, want %d", r.Status, cli.ExitUsage)
+	}
+	if i.ProjectID != 0 || len(h.CLI.FlagsSet(i)) != 0 {
+		t.Errorf("values of the previous run kept: %+v", i)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	h := newHarness()
+	var calls []string
+	var path []string
+	var command cli.Command
+	var c *cli.CLI
+	var runErr error
+	for _, name := range []string{"outer", "inner"} {
+		name := name
+		h.CLI.Use(func(next cli.RunFunc) cli.RunFunc {
+			return func(ctx context.Context) error {
+				calls = append(calls, name+" before")
+				path, command, c = cli.CommandPath(ctx), cli.CommandFromContext(ctx), cli.FromContext(ctx)
+				err := next(ctx)
+				if name == "inner" {
+					runErr = err
+				}
+				calls = append(calls, name+" after")
+				return err
+			}
+		})
+	}
+	r := h.Run("q", "fail")
+	if r.Status != 3 {
+		t.Fatalf("got status %d, want 3", r.Status)
+	}
+	want := []string{"outer before", "inner before", "inner after", "outer after"}
+	if strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got calls %q, want %q", calls, want)
+	}
+	if strings.Join(path, " ") != "queue fail" {
+		t.Errorf("got path %q, want [queue fail]", path)
+	}
+	if _, ok := command.(*fail); !ok {
+		t.Errorf("got command %T, want *fail", command)
+	}
+	if c != h.CLI {
+		t.Error("expected the running CLI in the context")
+	}
+	if runErr == nil || runErr.Error() != "failed" {
+		t.Errorf("got error %v from the command, want failed", runErr)
+	}
+}
+
+type Verbosity struct {
+	Verbose bool `flag:"verbose|v, print more"`
+}
+
+type parent struct {
+	Verbosity
+	parsed []string
+}
+
+func (p *parent) Parse(args []string) error {
+	p.parsed = args
+	if len(args) > 1 && args[1] == "invalid" {
+		return errors.New("invalid argument")
+	}
+	return nil
+}
+
+func (p *parent) Help() string     { return "Parent" }
+func (p *parent) Synopsis() string { return "Parent" }
+func (p *parent) Run(ctx context.Context) error {
+	return nil
+}
+
+func (p *parent) SubCommands() map[string]cli.Command {
+	return map[string]cli.Command{
+		"leaf": &leaf{parent: p},
+	}
+}
+
+type leaf struct {
+	Verbosity `flag:"base"`
+	parent    *parent
+}
+
+func (l *leaf) Help() string     { return "Leaf" }
+func (l *leaf) Synopsis() string { return "Leaf" }
+func (l *leaf) Run(ctx context.Context) error {
+	fmt.Fprintf(cli.FromContext(ctx).HelpWriter, "parent=%v leaf=%v\n", l.parent.Verbose, l.Verbose)
+	return nil
+}
+
+func TestPersistentShortAlias(t *testing.T) {
+	tests := []struct {
+		name       string
+		newFlagSet func() cli.Flagger
+		args       [][]string
+	}{
+		{
+			name: "std",
+			newFlagSet: func() cli.Flagger {
+				return &flag.FlagSet{Usage: func() {}}
+			},
+			args: [][]string{
+				{"parent", "-v", "leaf"},
+				{"parent", "leaf", "-v"},
+				{"parent", "leaf", "-verbose"},
+				{"parent", "leaf", "-base.verbose"},
+			},
+		},
+		{
+			name:       "gnu",
+			newFlagSet: func() cli.Flagger { return cli.NewGNUFlagSet() },
+			args: [][]string{
+				{"parent", "-v", "leaf"},
+				{"parent", "leaf", "-v"},
+				{"parent", "leaf", "--verbose"},
+				{"parent", "leaf", "--base.verbose"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := cli.NewRoot("app", "1.0.0")
+			root.AddCommand("parent", &parent{})
+			c := cli.NewWithRoot(root)
+			c.SetFlagSetFunc(tt.newFlagSet)
+			h := clitest.New(c)
+			for _, args := range tt.args {
+				if r := h.Run(args...); r.Status != cli.ExitOK || r.Stdout != "parent=true leaf=true\n" {
+					t.Errorf("%q: %s", args, r)
+				}
+			}
+			if r := h.Run("parent", "leaf", "-h"); r.Status != cli.ExitOK {
+				t.Errorf("help: %s", r)
+			}
+		})
+	}
+}
+
+func TestParentParse(t *testing.T) {
+	p := &parent{}
+	root := cli.NewRoot("app", "1.0.0")
+	root.AddCommand("parent", p)
+	h := clitest.New(cli.NewWithRoot(root))
+	r := h.Run("parent", "-v", "leaf", "-v", "x")
+	if r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if got, want := strings.Join(p.parsed, " "), "leaf -v x"; got != want {
+		t.Errorf("got parent args %q, want %q", got, want)
+	}
+	r = h.Run("parent", "leaf", "invalid")
+	if r.Status != cli.ExitUsage || !strings.HasPrefix(r.Stderr, "invalid argument\n") {
+		t.Errorf("expected the error of the parent: %s", r)
+	}
+}
+
+type echo struct {
+	Text string   `flag:"text, text to echo"`
+	List []string `flag:"l, list to echo"`
+	N    *int     `flag:"n, times to echo"`
+	Def  string   `flag:"def, echoed by default" default:"x"`
+	Pos  string   `arg:"pos,optional"`
+}
+
+func (e *echo) Help() string     { return "Echo" }
+func (e *echo) Synopsis() string { return "Echo" }
+func (e *echo) Run(ctx context.Context) error {
+	return nil
+}
+
+func TestRunResetsFields(t *testing.T) {
+	e := &echo{}
+	root := cli.NewRoot("app", "1.0.0")
+	root.AddCommand("echo", e)
+	c := cli.NewWithRoot(root)
+	h := clitest.New(c)
+	if r := h.Run("echo", "-text", "hi", "-l", "a", "-n", "3", "-def", "y", "p"); r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if e.Text != "hi" || len(e.List) != 1 || e.N == nil || e.Def != "y" || e.Pos != "p" {
+		t.Fatalf("flags not parsed: %+v", e)
+	}
+	if r := h.Run("echo"); r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if e.Text != "" || e.List != nil || e.N != nil || e.Def != "x" || e.Pos != "" {
+		t.Errorf("values of the previous run kept: %+v", e)
+	}
+	if got := c.FlagsSet(e); len(got) != 0 {
+		t.Errorf("got flags set %q", got)
+	}
+	if r := h.Run("echo", "-h"); strings.Contains(r.Stdout, "hi") || !strings.Contains(r.Stdout, `(default "x")`) {
+		t.Errorf("help shows the values of a previous run:\n%s", r.Stdout)
+	}
+}
+
+func TestFixedFlagSetRefused(t *testing.T) {
+	h := newHarness()
+	h.CLI.SetFlagSet(&flag.FlagSet{})
+	defer func() {
+		if recover() == nil {
+			t.Error("expected clitest to refuse a flag set given to SetFlagSet")
+		}
+	}()
+	h.Run("queue", "info", "-projectId", "1")
+}
+
+func TestSetFlagSetOnce(t *testing.T) {
+	root := cli.NewRoot("app", "1.0.0")
+	root.AddCommand("echo", &echo{})
+	c := cli.NewWithRoot(root)
+	var stderr strings.Builder
+	c.HelpWriter, c.ErrorWriter = ioutil.Discard, &stderr
+	c.SetFlagSet(&flag.FlagSet{Usage: func() {}})
+	if status := c.Run(context.Background(), []string{"app", "echo"}); status != cli.ExitOK {
+		t.Fatalf("got status %d, want %d", status, cli.ExitOK)
+	}
+	if status := c.Run(context.Background(), []string{"app", "echo"}); status != cli.ExitError {
+		t.Errorf("got status %d for a second run, want %d", status, cli.ExitError)
+	}
+	if !strings.Contains(stderr.String(), "SetFlagSetFunc") {
+		t.Errorf("got error %q", stderr.String())
+	}
+}
+
+type templated struct{}
+
+func (c *templated) Help() string                  { return "Templated" }
+func (c *templated) Synopsis() string              { return "Own template" }
+func (c *templated) Run(ctx context.Context) error { return nil }
+func (c *templated) HelpTemplate() string          { return "{{.Synopsis}} of {{.Help}}\n" }
+
+func TestHelpTemplate(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(c *cli.CLI)
+		args  []string
+		want  string
+	}{
+		{
+			name: "set template",
+			setup: func(c *cli.CLI) {
+				c.SetTemplate("{{.Usage}}: {{.Help}}\n")
+			},
+			args: []string{"queue", "info", "-h"},
+			want: "app queue info [options] [queue...]: Print queue info\n",
+		},
+		{
+			name: "template funcs",
+			setup: func(c *cli.CLI) {
+				c.SetTemplate("{{upper .Help}}\n")
+				c.AddTemplateFuncs(template.FuncMap{"upper": strings.ToUpper})
+			},
+			args: []string{"queue", "fail", "-h"},
+			want: "FAIL\n",
+		},
+		{
+			name: "override flagSet",
+			setup: func(c *cli.CLI) {
+				c.SetTemplate("{{flagSet .Command}}\n")
+				c.AddTemplateFuncs(template.FuncMap{
+					"flagSet": func(c cli.Command) string { return "no flags" },
+				})
+			},
+			args: []string{"queue", "info", "-h"},
+			want: "no flags\n",
+		},
+		{
+			name: "help templater",
+			setup: func(c *cli.CLI) {
+				c.SetTemplate("not used\n")
+			},
+			args: []string{"templated", "-h"},
+			want: "Own template of Templated\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness()
+			h.CLI.Root().AddCommand("templated", &templated{})
+			tt.setup(h.CLI)
+			r := h.Run(tt.args...)
+			if r.Status != cli.ExitOK || r.Stdout != tt.want {
+				t.Errorf("got %q, want %q\n%s", r.Stdout, tt.want, r)
+			}
+		})
+	}
+}
+
+type aliased struct {
+	aliases []string
+}
+
+func (c *aliased) Help() string                  { return "Aliased" }
+func (c *aliased) Synopsis() string              { return "Aliased" }
+func (c *aliased) Run(ctx context.Context) error { return nil }
+func (c *aliased) Aliases() []string             { return c.aliases }
+
+func TestAliases(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    bool
+		args      []string
+		status    int
+		path      string
+		errPrefix string
+	}{
+		{name: "name", args: []string{"queue", "fail"}, status: 3, path: "queue fail"},
+		{name: "root alias", args: []string{"q", "fail"}, status: 3, path: "queue fail"},
+		{name: "aliaser", args: []string{"st"}, path: "status"},
+		{name: "prefix disabled", args: []string{"stat"}, status: cli.ExitUsage, errPrefix: "unknown command"},
+		{name: "prefix", prefix: true, args: []string{"stat"}, path: "status"},
+		{name: "prefix of sub command", prefix: true, args: []string{"q", "fa"}, status: 3, path: "queue fail"},
+		{name: "prefix of alias", prefix: true, args: []string{"che"}, path: "status"},
+		{name: "exact match first", prefix: true, args: []string{"st"}, path: "status"},
+		{name: "ambiguous prefix", prefix: true, args: []string{"s"}, status: cli.ExitUsage, errPrefix: "ambiguous command 's', could be: start, status"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness()
+			h.CLI.Root().AddCommand("status", &aliased{aliases: []string{"st", "check"}})
+			h.CLI.Root().AddCommand("start", &aliased{})
+			h.CLI.PrefixMatching = tt.prefix
+			r := h.Run(tt.args...)
+			if r.Status != tt.status {
+				t.Fatalf("got status %d, want %d\n%s", r.Status, tt.status, r)
+			}
+			if tt.errPrefix != "" && !strings.HasPrefix(r.Stderr, tt.errPrefix) {
+				t.Errorf("got error %q, want %q", r.Stderr, tt.errPrefix)
+			}
+			if tt.path != "" && strings.Join(r.Path, " ") != tt.path {
+				t.Errorf("got path %q, want %q", r.Path, tt.path)
+			}
+		})
+	}
+}
+
+type group struct {
+	subCommands map[string]cli.Command
+}
+
+func (g *group) Help() string                        { return "Group" }
+func (g *group) Synopsis() string                    { return "Group" }
+func (g *group) Run(ctx context.Context) error       { return nil }
+func (g *group) SubCommands() map[string]cli.Command { return g.subCommands }
+
+func TestDuplicateAliases(t *testing.T) {
+	root := cli.NewRoot("app", "1.0.0")
+	if !root.AddCommand("status", &aliased{aliases: []string{"st"}}, "s") {
+		t.Fatal("status not added")
+	}
+	for name, add := range map[string]func() bool{
+		"name":          func() bool { return root.AddCommand("status", &aliased{}) },
+		"alias":         func() bool { return root.AddCommand("stop", &aliased{}, "st") },
+		"aliaser":       func() bool { return root.AddCommand("stop", &aliased{aliases: []string{"s"}}) },
+		"name is alias": func() bool { return root.AddCommand("st", &aliased{}) },
+		"twice":         func() bool { return root.AddCommand("stop", &aliased{}, "x", "x") },
+	} {
+		if add() {
+			t.Errorf("%s: added a command with a name already used", name)
+		}
+	}
+	root.AddCommand("group", &group{subCommands: map[string]cli.Command{
+		"first":  &aliased{aliases: []string{"second"}},
+		"second": &aliased{},
+	}})
+	r := clitest.New(cli.NewWithRoot(root)).Run("group", "second")
+	if r.Status != cli.ExitUsage || !strings.HasPrefix(r.Stderr, "ambiguous command 'second', could be: first, second\n") {
+		t.Errorf("expected an ambiguous command: %s", r)
+	}
+}
+
+// run runs c as the command cmd of a new CLI
+func run(c cli.Command, args ...string) *clitest.Result {
+	root := cli.NewRoot("app", "1.0.0")
+	root.AddCommand("cmd", c)
+	return clitest.New(cli.NewWithRoot(root)).Run(append([]string{"cmd"}, args...)...)
+}
+
+type slices struct {
+	Names     []string        `flag:"name, names"`
+	Split     []string        `flag:"split, split names" sep:","`
+	Ints      []int           `flag:"int, numbers" sep:";"`
+	Durations []time.Duration `flag:"duration, durations"`
+	Defaults  []string        `flag:"default, replaced defaults" default:"a"`
+}
+
+func (s *slices) Help() string                  { return "Slices" }
+func (s *slices) Synopsis() string              { return "Slices" }
+func (s *slices) Run(ctx context.Context) error { return nil }
+
+func TestSliceFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want slices
+		err  string
+	}{
+		{
+			name: "none",
+			want: slices{Defaults: []string{"a"}},
+		},
+		{
+			name: "repeated",
+			args: []string{"-name", "a,b", "-name", "c", "-duration", "1s", "-duration", "1m"},
+			want: slices{Names: []string{"a,b", "c"}, Durations: []time.Duration{time.Second, time.Minute}, Defaults: []string{"a"}},
+		},
+		{
+			name: "sep",
+			args: []string{"-split", "a,b", "-split", "c", "-int", "1;2"},
+			want: slices{Split: []string{"a", "b", "c"}, Ints: []int{1, 2}, Defaults: []string{"a"}},
+		},
+		{
+			name: "default replaced",
+			args: []string{"-default", "b", "-default", "c"},
+			want: slices{Defaults: []string{"b", "c"}},
+		},
+		{
+			name: "invalid element",
+			args: []string{"-int", "1;x"},
+			err:  `invalid value "1;x" for flag -int: parse error`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &slices{}
+			r := run(s, tt.args...)
+			if tt.err != "" {
+				if r.Status != cli.ExitUsage || !strings.HasPrefix(r.Stderr, tt.err+"\n") {
+					t.Errorf("got %s, want error %q", r, tt.err)
+				}
+				return
+			}
+			if r.Status != cli.ExitOK {
+				t.Fatal(r)
+			}
+			if !reflect.DeepEqual(*s, tt.want) {
+				t.Errorf("got %+v, want %+v", *s, tt.want)
+			}
+		})
+	}
+}
+
+type maps struct {
+	Labels  map[string]string `flag:"label, labels"`
+	Limits  map[string]int    `flag:"limit, limits" sep:","`
+	Default map[string]string `flag:"default, replaced defaults" default:"a=1"`
+}
+
+func (m *maps) Help() string                  { return "Maps" }
+func (m *maps) Synopsis() string              { return "Maps" }
+func (m *maps) Run(ctx context.Context) error { return nil }
+
+func TestMapFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want maps
+		err  string
+	}{
+		{
+			name: "none",
+			want: maps{Default: map[string]string{"a": "1"}},
+		},
+		{
+			name: "repeated",
+			args: []string{"-label", "a=1", "-label", "b=x=y", "-label", "c="},
+			want: maps{Labels: map[string]string{"a": "1", "b": "x=y", "c": ""}, Default: map[string]string{"a": "1"}},
+		},
+		{
+			name: "sep",
+			args: []string{"-limit", "a=1,b=2", "-limit", "a=3"},
+			want: maps{Limits: map[string]int{"a": 3, "b": 2}, Default: map[string]string{"a": "1"}},
+		},
+		{
+			name: "default replaced",
+			args: []string{"-default", "b=2"},
+			want: maps{Default: map[string]string{"b": "2"}},
+		},
+		{
+			name: "missing equal",
+			args: []string{"-label", "a"},
+			err:  `invalid value "a" for flag -label: expected key=value, got "a"`,
+		},
+		{
+			name: "empty key",
+			args: []string{"-label", "=1"},
+			err:  `invalid value "=1" for flag -label: expected key=value, got "=1"`,
+		},
+		{
+			name: "invalid value",
+			args: []string{"-limit", "a=1,b=x"},
+			err:  `invalid value "a=1,b=x" for flag -limit: value of "b": parse error`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &maps{}
+			r := run(m, tt.args...)
+			if tt.err != "" {
+				if r.Status != cli.ExitUsage || !strings.HasPrefix(r.Stderr, tt.err+"\n") {
+					t.Errorf("got %s, want error %q", r, tt.err)
+				}
+				return
+			}
+			if r.Status != cli.ExitOK {
+				t.Fatal(r)
+			}
+			if !reflect.DeepEqual(*m, tt.want) {
+				t.Errorf("got %+v, want %+v", *m, tt.want)
+			}
+		})
+	}
+}
+
+type times struct {
+	From  time.Time  `flag:"from, from date" layout:"2006-01-02"`
+	Until time.Time  `flag:"until, until time"`
+	Since *time.Time `flag:"since, since date" layout:"2006-01-02"`
+	Day   time.Time  `flag:"day, day" layout:"2006-01-02" default:"today"`
+}
+
+func (c *times) Help() string                  { return "Times" }
+func (c *times) Synopsis() string              { return "Times" }
+func (c *times) Run(ctx context.Context) error { return nil }
+
+func TestTimeFlags(t *testing.T) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	c := &times{}
+	r := run(c, "-from", "2020-01-02", "-until", "2020-01-02T03:04:05Z")
+	if r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if want := time.Date(2020, 1, 2, 0, 0, 0, 0, time.Local); !c.From.Equal(want) {
+		t.Errorf("got from %s, want %s", c.From, want)
+	}
+	if want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC); !c.Until.Equal(want) {
+		t.Errorf("got until %s, want %s", c.Until, want)
+	}
+	if c.Since != nil {
+		t.Errorf("got since %s, want nil", c.Since)
+	}
+	if !c.Day.Equal(today) {
+		t.Errorf("got day %s, want %s", c.Day, today)
+	}
+
+	c = &times{}
+	if r := run(c, "-since", "yesterday", "-day", "tomorrow"); r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if c.Since == nil || !c.Since.Equal(today.AddDate(0, 0, -1)) {
+		t.Errorf("got since %v, want yesterday", c.Since)
+	}
+	if !c.Day.Equal(today.AddDate(0, 0, 1)) {
+		t.Errorf("got day %s, want tomorrow", c.Day)
+	}
+
+	r = run(&times{}, "-from", "02/01/2020")
+	if want := `invalid value "02/01/2020" for flag -from: expected format 2006-01-02` + "\n"; r.Status != cli.ExitUsage || !strings.HasPrefix(r.Stderr, want) {
+		t.Errorf("got %s, want error %q", r, want)
+	}
+	r = run(&times{}, "-h")
+	if want := "from date (format: 2006-01-02)"; !strings.Contains(r.Stdout, want) {
+		t.Errorf("help does not show the layout %q:\n%s", want, r.Stdout)
+	}
+}
+
+type pointers struct {
+	Name      *string        `flag:"name, name"`
+	Count     *int           `flag:"count, count"`
+	Verbose   *bool          `flag:"verbose, verbose"`
+	Timeout   *time.Duration `flag:"timeout, timeout"`
+	Zero      int            `flag:"zero, zero"`
+	Untouched int            `flag:"untouched, untouched"`
+}
+
+func (p *pointers) Help() string                  { return "Pointers" }
+func (p *pointers) Synopsis() string              { return "Pointers" }
+func (p *pointers) Run(ctx context.Context) error { return nil }
+
+func TestPointerFlags(t *testing.T) {
+	p := &pointers{}
+	root := cli.NewRoot("app", "1.0.0")
+	root.AddCommand("cmd", p)
+	c := cli.NewWithRoot(root)
+	h := clitest.New(c)
+	if r := h.Run("cmd", "-count", "0", "-verbose", "-zero", "0"); r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if p.Name != nil || p.Timeout != nil {
+		t.Errorf("flags not given are set: name=%v timeout=%v", p.Name, p.Timeout)
+	}
+	if p.Count == nil || *p.Count != 0 || p.Verbose == nil || !*p.Verbose {
+		t.Errorf("got count=%v verbose=%v", p.Count, p.Verbose)
+	}
+	for name, want := range map[string]bool{"count": true, "verbose": true, "zero": true, "name": false, "untouched": false} {
+		if got := c.IsSet(p, name); got != want {
+			t.Errorf("IsSet(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if got := c.FlagsSet(p); strings.Join(got, " ") != "count verbose zero" {
+		t.Errorf("got flags set %q", got)
+	}
+	if r := h.Run("cmd", "-timeout", "x"); r.Status != cli.ExitUsage || p.Timeout != nil {
+		t.Errorf("got timeout %v\n%s", p.Timeout, r)
+	}
+}
+
+// noVisit is a flag set which can not tell the flags set
+type noVisit struct {
+	cli.Flagger
+}
+
+func TestFlagSetWithoutVisit(t *testing.T) {
+	const notVisit = "can not be used: the flag set cli_test.noVisit does not implement Visit"
+	tests := []struct {
+		name    string
+		command cli.Command
+		setup   func(c *cli.CLI)
+		args    []string
+		err     string
+	}{
+		{name: "plain", command: &slices{}, args: []string{"-int", "1"}},
+		{name: "required", command: &info{}, args: []string{"-projectId", "1"}, err: "required flag -projectId " + notVisit},
+		{
+			name:    "env",
+			command: &slices{},
+			setup:   func(c *cli.CLI) { c.EnvPrefix = "APP" },
+			err:     "environment variable APP_CMD_DEFAULT " + notVisit,
+		},
+		{
+			name:    "config",
+			command: &slices{},
+			setup:   func(c *cli.CLI) { c.ConfigFiles = []string{"missing.json"} },
+			err:     "config files " + notVisit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := cli.NewRoot("app", "1.0.0")
+			root.AddCommand("cmd", tt.command)
+			c := cli.NewWithRoot(root)
+			c.SetFlagSetFunc(func() cli.Flagger {
+				return noVisit{&flag.FlagSet{Usage: func() {}}}
+			})
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+			r := clitest.New(c).Run(append([]string{"cmd"}, tt.args...)...)
+			if tt.err == "" {
+				if r.Status != cli.ExitOK {
+					t.Fatal(r)
+				}
+				return
+			}
+			if r.Status != cli.ExitError || !strings.HasPrefix(r.Stderr, tt.err+"\n") {
+				t.Errorf("got %s, want error %q", r, tt.err)
+			}
+		})
+	}
+}
+
+type envs struct {
+	Name  string `flag:"name, name" env:"NAME"`
+	Count int    `flag:"count, count"`
+}
+
+func (e *envs) Help() string                  { return "Envs" }
+func (e *envs) Synopsis() string              { return "Envs" }
+func (e *envs) Run(ctx context.Context) error { return nil }
+
+func TestEnvFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want envs
+		err  string
+	}{
+		{name: "env tag", env: map[string]string{"NAME": "env"}, want: envs{Name: "env"}},
+		{name: "prefix", env: map[string]string{"APP_CMD_COUNT": "2"}, want: envs{Count: 2}},
+		{name: "tag replaces prefix", env: map[string]string{"APP_CMD_NAME": "prefix"}},
+		{name: "flag first", args: []string{"-name", "flag"}, env: map[string]string{"NAME": "env"}, want: envs{Name: "flag"}},
+		{
+			name: "invalid value",
+			env:  map[string]string{"APP_CMD_COUNT": "x"},
+			err:  `invalid value "x" for environment variable APP_CMD_COUNT: parse error`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &envs{}
+			root := cli.NewRoot("app", "1.0.0")
+			root.AddCommand("cmd", e)
+			c := cli.NewWithRoot(root)
+			c.EnvPrefix = "APP"
+			h := clitest.New(c)
+			h.Env = tt.env
+			r := h.Run(append([]string{"cmd"}, tt.args...)...)
+			if tt.err != "" {
+				if r.Status != cli.ExitUsage || !strings.HasPrefix(r.Stderr, tt.err+"\n") {
+					t.Errorf("got %s, want error %q", r, tt.err)
+				}
+				return
+			}
+			if r.Status != cli.ExitOK {
+				t.Fatal(r)
+			}
+			if *e != tt.want {
+				t.Errorf("got %+v, want %+v", *e, tt.want)
+			}
+		})
+	}
+}
+
+type defaults struct {
+	Name    string        `flag:"name, name" default:"tag"`
+	Host    string        `flag:"host, host" default:"tag"`
+	Timeout time.Duration `flag:"timeout, timeout" default:"1m"`
+	Ports   []int         `flag:"port, ports" default:"80"`
+}
+
+func (d *defaults) Help() string                  { return "Defaults" }
+func (d *defaults) Synopsis() string              { return "Defaults" }
+func (d *defaults) Run(ctx context.Context) error { return nil }
+func (d *defaults) SetDefaults() {
+	d.Host = "computed"
+}
+
+type invalidDefault struct {
+	Count int `flag:"count, count" default:"x"`
+}
+
+func (d *invalidDefault) Help() string                  { return "Invalid" }
+func (d *invalidDefault) Synopsis() string              { return "Invalid" }
+func (d *invalidDefault) Run(ctx context.Context) error { return nil }
+
+func TestDefaults(t *testing.T) {
+	d := &defaults{}
+	if r := run(d); r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	want := defaults{Name: "tag", Host: "computed", Timeout: time.Minute, Ports: []int{80}}
+	if !reflect.DeepEqual(*d, want) {
+		t.Errorf("got %+v, want %+v", *d, want)
+	}
+	d = &defaults{}
+	if r := run(d, "-host", "flag", "-port", "8080"); r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	want = defaults{Name: "tag", Host: "flag", Timeout: time.Minute, Ports: []int{8080}}
+	if !reflect.DeepEqual(*d, want) {
+		t.Errorf("got %+v, want %+v", *d, want)
+	}
+	r := run(&defaults{}, "-h")
+	for _, s := range []string{`(default "tag")`, `(default "computed")`, "(default 1m0s)", "(default 80)"} {
+		if !strings.Contains(r.Stdout, s) {
+			t.Errorf("help does not show %s:\n%s", s, r.Stdout)
+		}
+	}
+	r = run(&invalidDefault{})
+	if want := `invalid default value "x" for flag -count: parse error` + "\n"; !strings.HasPrefix(r.Stderr, want) {
+		t.Errorf("got %s, want error %q", r, want)
+	}
+}
+
+func TestGNUFlagSet(t *testing.T) {
+	h := newHarness()
+	h.CLI.SetFlagSetFunc(func() cli.Flagger {
+		return cli.NewGNUFlagSet()
+	})
+	h.Run("queue", "info", "--projectId=1", "-c", "a", "--customer", "b", "--", "-q").AssertGolden(t, "gnu")
+	h.Run("queue", "info", "-h").AssertGolden(t, "gnu_help")
+}
+
+type positionals struct {
+	Verbose bool   `flag:"v, verbose"`
+	Source  string `arg:"source"`
+	Count   int    `arg:"count"`
+	Target  string `arg:"target,optional"`
+}
+
+func (p *positionals) Help() string                  { return "Positionals" }
+func (p *positionals) Synopsis() string              { return "Positionals" }
+func (p *positionals) Run(ctx context.Context) error { return nil }
+
+func TestPositionals(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want positionals
+		err  string
+	}{
+		{name: "required only", args: []string{"a", "1"}, want: positionals{Source: "a", Count: 1}},
+		{name: "optional", args: []string{"-v", "a", "1", "b"}, want: positionals{Verbose: true, Source: "a", Count: 1, Target: "b"}},
+		{name: "missing", args: []string{}, err: "missing argument <source>"},
+		{name: "missing second", args: []string{"a"}, err: "missing argument <count>"},
+		{name: "extra", args: []string{"a", "1", "b", "c"}, err: `unexpected argument "c"`},
+		{name: "invalid", args: []string{"a", "x"}, err: `invalid value "x" for argument <count>: parse error`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &positionals{}
+			r := run(p, tt.args...)
+			if tt.err != "" {
+				if r.Status != cli.ExitUsage || !strings.HasPrefix(r.Stderr, tt.err+"\n") {
+					t.Errorf("got %s, want error %q", r, tt.err)
+				}
+				return
+			}
+			if r.Status != cli.ExitOK {
+				t.Fatal(r)
+			}
+			if *p != tt.want {
+				t.Errorf("got %+v, want %+v", *p, tt.want)
+			}
+		})
+	}
+	r := run(&positionals{}, "-h")
+	if want := "Usage: app cmd [options] <source> <count> [target]\n"; !strings.HasPrefix(r.Stdout, want) {
+		t.Errorf("got help %q, want usage %q", r.Stdout, want)
+	}
+}
+
+func TestConfigFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "first.json")
+	second := filepath.Join(dir, "second.ini")
+	files := map[string]string{
+		first:  `{"cmd": {"name": "first", "count": 1}}`,
+		second: "[cmd]\ncount = 2\n",
+	}
+	for path, data := range files {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e := &envs{}
+	root := cli.NewRoot("app", "1.0.0")
+	root.AddCommand("cmd", e)
+	c := cli.NewWithRoot(root)
+	c.ConfigFiles = []string{first, filepath.Join(dir, "missing.json"), second}
+	h := clitest.New(c)
+	if r := h.Run("cmd"); r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if want := (envs{Name: "first", Count: 2}); *e != want {
+		t.Errorf("got %+v, want %+v", *e, want)
+	}
+	if got, want := c.Origin(e, "count"), "config "+second+":2"; got != want {
+		t.Errorf("got origin %q, want %q", got, want)
+	}
+	h.Env["NAME"] = "env"
+	if r := h.Run("cmd", "-count", "3"); r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if want := (envs{Name: "env", Count: 3}); *e != want {
+		t.Errorf("got %+v, want %+v", *e, want)
+	}
+	if err := ioutil.WriteFile(second, []byte("[cmd]\ncount = x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := h.Run("cmd")
+	if want := fmt.Sprintf("invalid value %q for cmd.count in %s:2: parse error\n", "x", second); r.Status != cli.ExitUsage || !strings.HasPrefix(r.Stderr, want) {
+		t.Errorf("got %s, want error %q", r, want)
+	}
+}
+
+func TestDiscoverConfig(t *testing.T) {
+	home, err := ioutil.TempDir("", "clitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	dir := filepath.Join(home, ".config", "app")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	user := filepath.Join(dir, "config")
+	other := filepath.Join(home, "other.json")
+	files := map[string]string{
+		user:  "[queue.info]\nprojectId = 3\ncustomer = [\"a\", \"b\"]\n",
+		other: `{"queue": {"info": {"projectId": 4}}}`,
+	}
+	for path, data := range files {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := cli.NewRoot("app", "1.0.0")
+	root.AddCommand("queue", &queue{})
+	c := cli.NewWithRoot(root)
+	c.DiscoverConfig = true
+	h := clitest.New(c)
+	h.Env["HOME"] = home
+
+	r := h.Run("queue", "info", "-customer", "c")
+	if r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if got, want := c.Origin(r.Command, "projectId"), "config "+user+":2"; got != want {
+		t.Errorf("got origin %q, want %q", got, want)
+	}
+	if got := c.Origin(r.Command, "customer"); got != "flag" {
+		t.Errorf("got origin %q, want flag", got)
+	}
+	if got := c.Origin(r.Command, "format"); got != "" {
+		t.Errorf("got origin %q for a default", got)
+	}
+
+	r = h.Run("--config", other, "queue", "info")
+	if r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if i := r.Command.(*info); i.ProjectID != 4 || i.Customer != nil {
+		t.Errorf("got %+v, want the values of %s only", i, other)
+	}
+	if c.ConfigFile() != other {
+		t.Errorf("got config file %q, want %q", c.ConfigFile(), other)
+	}
+	if r := h.Run("--config", filepath.Join(home, "missing"), "queue", "info"); r.Status != cli.ExitUsage {
+		t.Errorf("expected an error for a missing config file: %s", r)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.ini")
+	config := `[cmd]
+name = "default"
+count = 1
+
+[profiles.staging.cmd]
+name = "staging"
+
+[profiles.prod.cmd]
+name = "prod"
+`
+	tests := []struct {
+		name    string
+		config  string
+		args    []string
+		env     map[string]string
+		want    envs
+		profile string
+		stderr  string
+		status  int
+	}{
+		{name: "none", want: envs{Name: "default", Count: 1}},
+		{name: "config", config: `profile = "staging"`, want: envs{Name: "staging", Count: 1}, profile: "staging"},
+		{
+			name:    "env",
+			config:  `profile = "staging"`,
+			env:     map[string]string{"APP_PROFILE": "prod"},
+			want:    envs{Name: "prod", Count: 1},
+			profile: "prod",
+		},
+		{
+			name:    "flag",
+			env:     map[string]string{"APP_PROFILE": "prod"},
+			args:    []string{"--profile", "staging"},
+			want:    envs{Name: "staging", Count: 1},
+			profile: "staging",
+		},
+		{
+			name:   "unknown in config",
+			config: `profile = "gone"`,
+			want:   envs{Name: "default", Count: 1},
+			stderr: "warning: unknown profile \"gone\" selected by config " + path + ":1 is ignored\n",
+		},
+		{
+			name:   "unknown in env",
+			env:    map[string]string{"APP_PROFILE": "gone"},
+			want:   envs{Name: "default", Count: 1},
+			stderr: "warning: unknown profile \"gone\" selected by APP_PROFILE is ignored\n",
+		},
+		{
+			name:   "unknown flag",
+			args:   []string{"--profile", "gone"},
+			stderr: "unknown profile \"gone\"\n",
+			status: cli.ExitUsage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(path, []byte(tt.config+"\n"+config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			e := &envs{}
+			root := cli.NewRoot("app", "1.0.0")
+			root.AddCommand("cmd", e)
+			c := cli.NewWithRoot(root)
+			c.ConfigFiles = []string{path}
+			h := clitest.New(c)
+			for k, v := range tt.env {
+				h.Env[k] = v
+			}
+			r := h.Run(append(tt.args, "cmd")...)
+			if tt.status != cli.ExitOK {
+				if r.Status != tt.status || !strings.HasPrefix(r.Stderr, tt.stderr) {
+					t.Errorf("got %s, want status %d and error %q", r, tt.status, tt.stderr)
+				}
+				return
+			}
+			if r.Status != cli.ExitOK || r.Stderr != tt.stderr {
+				t.Fatalf("got %s, want warnings %q", r, tt.stderr)
+			}
+			if *e != tt.want || c.Profile() != tt.profile {
+				t.Errorf("got %+v with profile %q, want %+v with profile %q", *e, c.Profile(), tt.want, tt.profile)
+			}
+		})
+	}
+}
+
+func TestConfigCommand(t *testing.T) {
+	home, err := ioutil.TempDir("", "clitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	dir := filepath.Join(home, ".config", "app")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `profile = "gone"
+
+[queue.info]
+projectId = 3
+customer = ["a", "b"]
+
+[profiles.staging.queue.info]
+format = "json"
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "config"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	root := cli.NewRoot("app", "1.0.0")
+	root.AddCommand("queue", &queue{})
+	root.AddCommand("config", command.NewConfig())
+	root.AddCommand("profile", command.NewProfile())
+	c := cli.NewWithRoot(root)
+	c.DiscoverConfig = true
+	h := clitest.New(c)
+	h.Env["HOME"] = home
+
+	steps := []struct {
+		args   []string
+		status int
+		stdout string
+	}{
+		{args: []string{"config", "get", "queue.info.projectId"}, stdout: "3\n"},
+		{args: []string{"config", "set", "queue.info.projectId", "x"}, status: cli.ExitError},
+		{args: []string{"config", "set", "queue.info.projectid", "4"}, status: cli.ExitError},
+		{args: []string{"config", "set", "queue.info.projectId", "4"}},
+		{args: []string{"config", "get", "queue.info.projectId"}, stdout: "4\n"},
+		{args: []string{"profile", "use", "staging"}, stdout: "Switched to profile \"staging\"\n"},
+		{args: []string{"profile", "list"}, stdout: "* staging\n"},
+		{args: []string{"config", "get", "queue.info.format"}, status: cli.ExitError},
+		{args: []string{"config", "unset", "queue.info.customer"}},
+		{args: []string{"--profile", "nope", "queue", "info"}, status: cli.ExitUsage},
+	}
+	for _, s := range steps {
+		r := h.Run(s.args...)
+		if r.Status != s.status {
+			t.Fatalf("%q: got status %d, want %d\n%s", s.args, r.Status, s.status, r)
+		}
+		if s.stdout != "" && r.Stdout != s.stdout {
+			t.Fatalf("%q: got %q, want %q", s.args, r.Stdout, s.stdout)
+		}
+	}
+	for name, args := range map[string][]string{
+		"config_list":    {"config", "list"},
+		"config_profile": {"queue", "info"},
+	} {
+		r := h.Run(args...)
+		r.Stdout = strings.Replace(r.Stdout, home, "$HOME", -1)
+		r.AssertGolden(t, name)
+	}
+}
@@ -0,0 +1,172 @@
+// clitest provides utilities to test command trees of a cli.CLI in process.
+//
+//     func TestQueueInfo(t *testing.T) {
+//         h := clitest.New(cli.NewWithRoot(newRoot()))
+//         r := h.Run("queue", "info", "-v")
+//         if r.Status != cli.ExitOK {
+//             t.Fatal(r.Stderr)
+//         }
+//         r.AssertGolden(t, "queue_info")
+//     }
+package clitest
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Ak-Army/cli"
+)
+
+var update = flag.Bool("clitest.update", false, "update the golden files of clitest")
+
+// Harness runs a CLI with the given environment and input. It can run the
+// CLI many times, the flag and argument fields of the commands are restored
+// by every Run, their other fields keep the state left by the previous runs.
+type Harness struct {
+	CLI *cli.CLI
+	// Env holds the environment seen by the CLI, the process environment
+	// is not visible. User config files are found through HOME and
+	// XDG_CONFIG_HOME of Env.
+	Env map[string]string
+	// Dir is the working directory the project config file is searched
+	// from, none is searched when empty. The system config is never read.
+	Dir string
+	// Stdin is given as InputReader of the CLI.
+	Stdin io.Reader
+	// Context is passed to the CLI, defaults to context.Background().
+	Context context.Context
+}
+
+// Result holds the outcome of a run
+type Result struct {
+	// Status is the exit status returned by the CLI
+	Status int
+	// Stdout holds the output written to the HelpWriter
+	Stdout string
+	// Stderr holds the output written to the ErrorWriter
+	Stderr string
+	// Path is the path of the resolved command
+	Path []string
+	// Command is the resolved command
+	Command cli.Command
+}
+
+// New returns a new Harness for c with an empty environment. The CLI must get
+// its flag sets from CLI.SetFlagSetFunc, not from CLI.SetFlagSet, to be run
+// more than once.
+func New(c *cli.CLI) *Harness {
+	return &Harness{
+		CLI:     c,
+		Env:     make(map[string]string),
+		Context: context.Background(),
+	}
+}
+
+// Run runs the CLI with args, the name of the root command is prepended.
+func (h *Harness) Run(args ...string) *Result {
+	return h.run(h.Env, append([]string{h.CLI.Root().Name}, args...))
+}
+
+// Complete runs the CLI as the shell does to complete line, and returns the
+// proposed completions in Stdout.
+func (h *Harness) Complete(line string) *Result {
+	env := make(map[string]string, len(h.Env)+2)
+	for k, v := range h.Env {
+		env[k] = v
+	}
+	env["COMP_LINE"] = line
+	env["COMP_POINT"] = strconv.Itoa(len(line))
+	return h.run(env, []string{h.CLI.Root().Name})
+}
+
+func (h *Harness) run(env map[string]string, args []string) *Result {
+	c := h.CLI
+	if !c.FlagSetReusable() {
+		panic("clitest: the CLI uses a flag set given to SetFlagSet, which can be parsed only once, use SetFlagSetFunc")
+	}
+	helpWriter, errorWriter := c.HelpWriter, c.ErrorWriter
+	inputReader, lookupEnv := c.InputReader, c.LookupEnv
+	signals := c.Signals
+	systemConfigDir, getwd := c.SystemConfigDir, c.Getwd
+	defer func() {
+		c.HelpWriter, c.ErrorWriter = helpWriter, errorWriter
+		c.InputReader, c.LookupEnv = inputReader, lookupEnv
+		c.Signals = signals
+		c.SystemConfigDir, c.Getwd = systemConfigDir, getwd
+	}()
+
+	var stdout, stderr bytes.Buffer
+	c.HelpWriter = &stdout
+	c.ErrorWriter = &stderr
+	c.InputReader = h.Stdin
+	if c.InputReader == nil {
+		c.InputReader = strings.NewReader("")
+	}
+	c.LookupEnv = func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	c.Signals = []os.Signal{}
+	c.SystemConfigDir = ""
+	c.Getwd = nil
+	if h.Dir != "" {
+		dir := h.Dir
+		c.Getwd = func() (string, error) {
+			return dir, nil
+		}
+	}
+
+	ctx := h.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	r := &Result{}
+	r.Status = c.Run(ctx, args)
+	r.Stdout = stdout.String()
+	r.Stderr = stderr.String()
+	r.Path, r.Command = c.Resolved()
+	return r
+}
+
+// String returns the status and the outputs of the run
+func (r *Result) String() string {
+	return fmt.Sprintf("status: %d\nstdout:\n%s\nstderr:\n%s", r.Status, r.Stdout, r.Stderr)
+}
+
+// AssertGolden compares the result with testdata/<name>.golden
+func (r *Result) AssertGolden(t testing.TB, name string) {
+	t.Helper()
+	AssertGolden(t, name, r.String())
+}
+
+// AssertGolden compares got with the content of testdata/<name>.golden. The
+// golden file is rewritten instead when the tests run with -clitest.update.
+func AssertGolden(t testing.TB, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %s", err)
+	}
+	if string(want) != got {
+		t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package clitest_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Ak-Army/cli"
+	"github.com/Ak-Army/cli/clitest"
+)
+
+type echo struct {
+	Text  string `flag:"text, text to echo"`
+	Token string `flag:"token, token to echo" secret:"true"`
+}
+
+func (e *echo) Help() string     { return "Echo the text" }
+func (e *echo) Synopsis() string { return "Echo the text" }
+func (e *echo) Run(ctx context.Context) error {
+	fmt.Fprintf(cli.FromContext(ctx).HelpWriter, "%s %s\n", e.Text, e.Token)
+	return nil
+}
+
+func newHarness() *clitest.Harness {
+	root := cli.NewRoot("echoer", "1.0.0")
+	root.AddCommand("echo", &echo{})
+	c := cli.NewWithRoot(root)
+	c.EnvPrefix = "ECHOER"
+	c.DiscoverConfig = true
+	return clitest.New(c)
+}
+
+func TestEnvIsolated(t *testing.T) {
+	os.Setenv("ECHOER_ECHO_TEXT", "from process")
+	defer os.Unsetenv("ECHOER_ECHO_TEXT")
+	h := newHarness()
+	if r := h.Run("echo"); r.Stdout != " \n" {
+		t.Errorf("process environment is visible: %q", r.Stdout)
+	}
+	h.Env["ECHOER_ECHO_TEXT"] = "from harness"
+	if r := h.Run("echo"); r.Stdout != "from harness \n" {
+		t.Errorf("got %q", r.Stdout)
+	}
+}
+
+func TestStdin(t *testing.T) {
+	h := newHarness()
+	h.Stdin = strings.NewReader("s3cret\n")
+	r := h.Run("echo", "-token", "-")
+	if r.Stdout != " s3cret\n" {
+		t.Errorf("got %q", r.Stdout)
+	}
+}
+
+func TestProjectConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, ".echoer.json"), []byte(`{"echo": {"text": "project"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	h := newHarness()
+	if r := h.Run("echo"); r.Stdout != " \n" {
+		t.Errorf("project config read without Dir: %q", r.Stdout)
+	}
+	h.Dir = sub
+	if r := h.Run("echo"); r.Stdout != "project \n" {
+		t.Errorf("got %q", r.Stdout)
+	}
+}
+
+func TestResult(t *testing.T) {
+	r := newHarness().Run("echo", "-text", "hi")
+	if r.Status != cli.ExitOK {
+		t.Fatal(r)
+	}
+	if strings.Join(r.Path, " ") != "echo" {
+		t.Errorf("got path %q", r.Path)
+	}
+	if _, ok := r.Command.(*echo); !ok {
+		t.Errorf("got command %T", r.Command)
+	}
+}
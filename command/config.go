@@ -0,0 +1,182 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Ak-Army/cli"
+)
+
+// Config reads and writes the values of the config file. Keys are the path
+// of a command and the name of its flag, like queue.info.customer.
+type Config struct{}
+
+func NewConfig() *Config {
+	return &Config{}
+}
+
+func (c *Config) Help() string {
+	return `Get, set, unset and list the flag values of the config file, the file given
+with --config or the user config file.
+Keys are the command path and the flag name, like queue.info.customer, values
+are checked like flags given on the command line.`
+}
+
+func (c *Config) Synopsis() string {
+	return "Get and set persisted flag values"
+}
+
+func (c *Config) SubCommands() map[string]cli.Command {
+	return map[string]cli.Command{
+		"get":   &ConfigGet{},
+		"set":   &ConfigSet{},
+		"unset": &ConfigUnset{},
+		"list":  &ConfigList{},
+	}
+}
+
+func (c *Config) Run(_ context.Context) error {
+	return errors.New("select a sub command")
+}
+
+// ConfigGet prints the value of a key in the config file, one line for each
+// item of lists.
+type ConfigGet struct {
+	Key string `arg:"key"`
+}
+
+func (c *ConfigGet) Help() string {
+	return `Print the value of a key in the config file, like config get queue.info.customer.`
+}
+
+func (c *ConfigGet) Synopsis() string {
+	return "Print the value of a key"
+}
+
+func (c *ConfigGet) Run(ctx context.Context) error {
+	app := cli.FromContext(ctx)
+	if err := app.CheckConfig(c.Key); err != nil {
+		return err
+	}
+	config, _, err := loadConfigFile(app)
+	if err != nil {
+		return err
+	}
+	v, ok := config.Lookup(c.Key)
+	if !ok {
+		return fmt.Errorf("%s is not set", c.Key)
+	}
+	for _, s := range v.Values {
+		fmt.Fprintln(app.HelpWriter, s)
+	}
+	return nil
+}
+
+// ConfigSet stores the value of a key in the config file, more values make
+// a list.
+type ConfigSet struct {
+	Key    string   `arg:"key"`
+	Values []string `arg:"value,variadic"`
+}
+
+func (c *ConfigSet) Help() string {
+	return `Store the value of a key in the config file, like config set queue.info.customer first second.`
+}
+
+func (c *ConfigSet) Synopsis() string {
+	return "Set the value of a key"
+}
+
+func (c *ConfigSet) Run(ctx context.Context) error {
+	app := cli.FromContext(ctx)
+	if err := app.CheckConfig(c.Key, c.Values...); err != nil {
+		return err
+	}
+	return updateConfig(app, func(config *cli.Config) {
+		config.Set(c.Key, c.Values...)
+	})
+}
+
+// ConfigUnset removes a key from the config file.
+type ConfigUnset struct {
+	Key string `arg:"key"`
+}
+
+func (c *ConfigUnset) Help() string {
+	return `Remove a key from the config file, like config unset queue.info.customer.`
+}
+
+func (c *ConfigUnset) Synopsis() string {
+	return "Remove a key"
+}
+
+func (c *ConfigUnset) Run(ctx context.Context) error {
+	app := cli.FromContext(ctx)
+	if err := app.CheckConfig(c.Key); err != nil {
+		return err
+	}
+	return updateConfig(app, func(config *cli.Config) {
+		config.Unset(c.Key)
+	})
+}
+
+// ConfigList prints every value of the config file with its line, values
+// of secret flags are masked.
+type ConfigList struct{}
+
+func (c *ConfigList) Help() string {
+	return `List the values of the config file, with the line they were read from.`
+}
+
+func (c *ConfigList) Synopsis() string {
+	return "List the values"
+}
+
+func (c *ConfigList) Run(ctx context.Context) error {
+	app := cli.FromContext(ctx)
+	config, _, err := loadConfigFile(app)
+	if err != nil {
+		return err
+	}
+	config, err = app.RedactConfig(config)
+	if err != nil {
+		return err
+	}
+	for _, key := range config.Keys() {
+		v, _ := config.Lookup(key)
+		fmt.Fprintf(app.HelpWriter, "%s=%s", key, strings.Join(v.Values, ","))
+		if v.Origin != "" {
+			fmt.Fprintf(app.HelpWriter, "\t# %s", v.Origin)
+		}
+		fmt.Fprintln(app.HelpWriter)
+	}
+	return nil
+}
+
+// loadConfigFile reads the config file of app, which is empty when it does
+// not exist yet. Values of other config files and of the profile are not
+// included, so get and list show what set and unset wrote.
+func loadConfigFile(app *cli.CLI) (*cli.Config, string, error) {
+	file := app.ConfigFile()
+	if file == "" {
+		return nil, "", errors.New("no config file")
+	}
+	config, err := cli.LoadConfig(file)
+	if os.IsNotExist(err) {
+		return cli.NewConfig(), file, nil
+	}
+	return config, file, err
+}
+
+// updateConfig applies fn to the config file of app and writes it back
+func updateConfig(app *cli.CLI, fn func(config *cli.Config)) error {
+	config, file, err := loadConfigFile(app)
+	if err != nil {
+		return err
+	}
+	fn(config)
+	return config.WriteFile(file, "")
+}
@@ -0,0 +1,128 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Ak-Army/cli"
+)
+
+// Profile lists, selects and shows the profiles of the config, named groups
+// of flag values like:
+//
+//     [profiles.staging.queue.info]
+//     customer = "test"
+type Profile struct{}
+
+func NewProfile() *Profile {
+	return &Profile{}
+}
+
+func (p *Profile) Help() string {
+	return `Manage the profiles of the config file.
+A profile is selected with the --profile flag, the <NAME>_PROFILE environment
+variable, or by profile use. Its values override the config, flags and
+environment variables override the profile.`
+}
+
+func (p *Profile) Synopsis() string {
+	return "List, select and show profiles"
+}
+
+func (p *Profile) SubCommands() map[string]cli.Command {
+	return map[string]cli.Command{
+		"list": &ProfileList{},
+		"use":  &ProfileUse{},
+		"show": &ProfileShow{},
+	}
+}
+
+func (p *Profile) Run(_ context.Context) error {
+	return errors.New("select a sub command")
+}
+
+// ProfileList prints the profiles, the current one is marked with *.
+type ProfileList struct{}
+
+func (p *ProfileList) Help() string {
+	return `List the profiles of the config, the current one is marked with *.`
+}
+
+func (p *ProfileList) Synopsis() string {
+	return "List profiles"
+}
+
+func (p *ProfileList) Run(ctx context.Context) error {
+	c := cli.FromContext(ctx)
+	for _, name := range c.Config().Profiles() {
+		mark := " "
+		if name == c.Profile() {
+			mark = "*"
+		}
+		fmt.Fprintf(c.HelpWriter, "%s %s\n", mark, name)
+	}
+	return nil
+}
+
+// ProfileUse stores the current profile in the config file.
+type ProfileUse struct {
+	Name string `arg:"name"`
+}
+
+func (p *ProfileUse) Help() string {
+	return `Select the profile used when no --profile flag or environment variable is given.`
+}
+
+func (p *ProfileUse) Synopsis() string {
+	return "Select the current profile"
+}
+
+func (p *ProfileUse) Run(ctx context.Context) error {
+	c := cli.FromContext(ctx)
+	if c.Config().Profile(p.Name) == nil {
+		return fmt.Errorf("unknown profile %q", p.Name)
+	}
+	err := updateConfig(c, func(config *cli.Config) {
+		config.Set("profile", p.Name)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.HelpWriter, "Switched to profile %q\n", p.Name)
+	return nil
+}
+
+// ProfileShow prints the values of a profile, the current one by default.
+// Values of secret flags are masked.
+type ProfileShow struct {
+	Name string `arg:"name,optional"`
+}
+
+func (p *ProfileShow) Help() string {
+	return `Show the values of a profile, the current one by default.`
+}
+
+func (p *ProfileShow) Synopsis() string {
+	return "Show the values of a profile"
+}
+
+func (p *ProfileShow) Run(ctx context.Context) error {
+	c := cli.FromContext(ctx)
+	name := p.Name
+	if name == "" {
+		name = c.Profile()
+	}
+	if name == "" {
+		return errors.New("no profile selected")
+	}
+	profile := c.Config().Profile(name)
+	if profile == nil {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	profile, err := c.RedactConfig(profile)
+	if err != nil {
+		return err
+	}
+	return profile.Write(c.HelpWriter, cli.ConfigINI)
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"flag"
+	"strings"
+)
+
+// complete writes the completions of the last argument to the HelpWriter:
+// sub command names, flag names or the choices of a flag value.
+func (cli *CLI) complete(args []string) {
+	last := args[len(args)-1]
+	if strings.HasPrefix(last, "-") {
+		if i := strings.Index(last, "="); i > 0 {
+			cli.completeChoices(strings.TrimLeft(last[:i], "-"), last[i+1:], last[:i+1])
+			return
+		}
+		cli.completeFlags(strings.TrimLeft(last, "-"))
+		return
+	}
+	if len(args) > 2 {
+		prev := args[len(args)-2]
+		if strings.HasPrefix(prev, "-") && !strings.Contains(prev, "=") {
+			if f := lookupField(cli.fields, strings.TrimLeft(prev, "-")); f != nil && f.choices != nil {
+				cli.completeChoices(f.name, last, "")
+				return
+			}
+		}
+	}
+	if len(cli.lastCommandsName) > 0 {
+		for _, name := range cli.lastCommandsName {
+			if strings.HasPrefix(name, last) {
+				cli.HelpWriter.Write([]byte(name + "\n"))
+			}
+		}
+		return
+	}
+	cli.completeFlags(last)
+}
+
+func (cli *CLI) completeFlags(prefix string) {
+	cli.flagSet.VisitAll(func(f *flag.Flag) {
+		// short aliases defined as flags are not offered
+		if field := lookupField(cli.fields, f.Name); field != nil && field.short == f.Name {
+			return
+		}
+		if strings.HasPrefix(f.Name, prefix) {
+			cli.HelpWriter.Write([]byte(cli.flagArg(f.Name) + "\n"))
+		}
+	})
+}
+
+func (cli *CLI) completeChoices(name string, prefix string, before string) {
+	f := lookupField(cli.fields, name)
+	if f == nil {
+		return
+	}
+	for _, choice := range f.choices {
+		if strings.HasPrefix(choice, prefix) {
+			cli.HelpWriter.Write([]byte(before + choice + "\n"))
+		}
+	}
+}
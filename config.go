@@ -0,0 +1,405 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Config file formats
+const (
+	// ConfigJSON is a JSON object, nested objects are joined by dots.
+	ConfigJSON = "json"
+	// ConfigINI is an INI file with [sections] and key = value lines, values
+	// can be TOML strings, numbers, booleans and one line arrays.
+	ConfigINI = "ini"
+)
+
+// Config holds flag values read from configuration files. Keys are the path
+// of the command and the name of the flag joined by dots, like
+// queue.info.customer or queue.info.base.v.
+type Config struct {
+	values map[string]*ConfigValue
+}
+
+// ConfigValue is a value of a Config, with the place it was read from
+type ConfigValue struct {
+	// Values holds the value, or the items of a list
+	Values []string
+	// Origin is the file and line the value was read from
+	Origin string
+}
+
+// NewConfig returns an empty Config
+func NewConfig() *Config {
+	return &Config{
+		values: make(map[string]*ConfigValue),
+	}
+}
+
+// LoadConfig reads the config file at path. The format is selected by the
+// extension of the file: .json for JSON, .ini, .toml, .conf or .cfg for INI.
+// Files without these extensions are read as JSON if they start with "{".
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseConfig(bytes.NewReader(data), configFormat(path, data), path)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %v", path, err)
+	}
+	return c, nil
+}
+
+// configFormat returns the format of the config file at path with data
+func configFormat(path string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ConfigJSON
+	case ".ini", ".toml", ".conf", ".cfg":
+		return ConfigINI
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return ConfigJSON
+	}
+	return ConfigINI
+}
+
+// ParseConfig reads a config in the given format from r, origin is recorded
+// as the origin of the values.
+func ParseConfig(r io.Reader, format string, origin string) (*Config, error) {
+	c := NewConfig()
+	switch format {
+	case ConfigJSON:
+		return c, c.parseJSON(r, origin)
+	case ConfigINI:
+		return c, c.parseINI(r, origin)
+	}
+	return nil, fmt.Errorf("unknown config format %q", format)
+}
+
+// Lookup returns the value of key
+func (c *Config) Lookup(key string) (*ConfigValue, bool) {
+	v, ok := c.values[key]
+	return v, ok
+}
+
+// Set sets the value of key, more values make a list
+func (c *Config) Set(key string, values ...string) {
+	c.values[key] = &ConfigValue{Values: values}
+}
+
+// Unset removes key from the config
+func (c *Config) Unset(key string) {
+	delete(c.values, key)
+}
+
+// Keys returns the sorted keys of the config
+func (c *Config) Keys() []string {
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Merge sets the values of other in c, overriding the existing ones
+func (c *Config) Merge(other *Config) {
+	for k, v := range other.values {
+		c.values[k] = v
+	}
+}
+
+func (c *Config) parseJSON(r io.Reader, origin string) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var root map[string]interface{}
+	if err := dec.Decode(&root); err != nil {
+		return err
+	}
+	return c.flatten("", root, origin)
+}
+
+func (c *Config) flatten(prefix string, v interface{}, origin string) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range v {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			if err := c.flatten(key, sub, origin); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := jsonScalar(item)
+			if !ok {
+				return fmt.Errorf("%s: lists can hold only strings, numbers and booleans", prefix)
+			}
+			values = append(values, s)
+		}
+		c.values[prefix] = &ConfigValue{Values: values, Origin: origin}
+	case nil:
+	default:
+		s, ok := jsonScalar(v)
+		if !ok {
+			return fmt.Errorf("%s: unsupported value", prefix)
+		}
+		c.values[prefix] = &ConfigValue{Values: []string{s}, Origin: origin}
+	}
+	return nil
+}
+
+func jsonScalar(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+func (c *Config) parseINI(r io.Reader, origin string) error {
+	scanner := bufio.NewScanner(r)
+	section := ""
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return fmt.Errorf("line %d: unterminated section", n)
+			}
+			section = strings.TrimSpace(line[1:end])
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return fmt.Errorf("line %d: expected key = value", n)
+		}
+		key := strings.Trim(strings.TrimSpace(line[:eq]), `"`)
+		if section != "" {
+			key = section + "." + key
+		}
+		values, err := parseINIValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		c.values[key] = &ConfigValue{Values: values, Origin: fmt.Sprintf("%s:%d", origin, n)}
+	}
+	return scanner.Err()
+}
+
+func parseINIValue(s string) ([]string, error) {
+	if !strings.HasPrefix(s, "[") {
+		v, rest, err := parseINIScalar(s, "")
+		if err != nil {
+			return nil, err
+		}
+		return []string{v}, checkINIComment(rest)
+	}
+	values := []string{}
+	rest := strings.TrimSpace(s[1:])
+	for !strings.HasPrefix(rest, "]") {
+		v, r, err := parseINIScalar(rest, ",]")
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		rest = strings.TrimSpace(r)
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, errors.New("unterminated list")
+		}
+	}
+	return values, checkINIComment(rest[1:])
+}
+
+// parseINIScalar parses a quoted or bare value at the start of s, bare
+// values end at a comment or at one of the stop characters.
+func parseINIScalar(s string, stops string) (string, string, error) {
+	if s == "" {
+		return "", "", nil
+	}
+	switch s[0] {
+	case '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				v, err := strconv.Unquote(s[:i+1])
+				return v, s[i+1:], err
+			}
+		}
+		return "", "", errors.New("unterminated string")
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", errors.New("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	for i := 0; i < len(s); i++ {
+		comment := (s[i] == '#' || s[i] == ';') && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t')
+		if comment || strings.IndexByte(stops, s[i]) >= 0 {
+			return strings.TrimSpace(s[:i]), s[i:], nil
+		}
+	}
+	return strings.TrimSpace(s), "", nil
+}
+
+func checkINIComment(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && rest[0] != '#' && rest[0] != ';' {
+		return fmt.Errorf("unexpected %q after value", rest)
+	}
+	return nil
+}
+
+// WriteFile writes the config to path in the given format, the format is
+// selected like LoadConfig does if it is empty.
+func (c *Config) WriteFile(path string, format string) error {
+	if format == "" {
+		data, _ := ioutil.ReadFile(path)
+		format = configFormat(path, data)
+	}
+	var buf bytes.Buffer
+	if err := c.Write(&buf, format); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// Write writes the config to w in the given format
+func (c *Config) Write(w io.Writer, format string) error {
+	switch format {
+	case ConfigJSON:
+		return c.writeJSON(w)
+	case ConfigINI:
+		return c.writeINI(w)
+	}
+	return fmt.Errorf("unknown config format %q", format)
+}
+
+func (c *Config) writeJSON(w io.Writer) error {
+	root := make(map[string]interface{})
+	for _, key := range c.Keys() {
+		parts := strings.Split(key, ".")
+		m := root
+		for _, p := range parts[:len(parts)-1] {
+			sub, ok := m[p].(map[string]interface{})
+			if !ok {
+				if _, exists := m[p]; exists {
+					return fmt.Errorf("key %q conflicts with a value", key)
+				}
+				sub = make(map[string]interface{})
+				m[p] = sub
+			}
+			m = sub
+		}
+		last := parts[len(parts)-1]
+		if _, exists := m[last]; exists {
+			return fmt.Errorf("key %q conflicts with a section", key)
+		}
+		values := c.values[key].Values
+		if len(values) == 1 {
+			m[last] = jsonValue(values[0])
+			continue
+		}
+		list := make([]interface{}, len(values))
+		for i, v := range values {
+			list[i] = jsonValue(v)
+		}
+		m[last] = list
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(root)
+}
+
+func jsonValue(s string) interface{} {
+	if isBareValue(s) {
+		return json.RawMessage(s)
+	}
+	return s
+}
+
+func (c *Config) writeINI(w io.Writer) error {
+	sections := make(map[string][]string)
+	var names []string
+	for _, key := range c.Keys() {
+		section := ""
+		if i := strings.LastIndexByte(key, '.'); i >= 0 {
+			section = key[:i]
+		}
+		if _, ok := sections[section]; !ok {
+			names = append(names, section)
+		}
+		sections[section] = append(sections[section], key)
+	}
+	sort.Strings(names)
+	bw := bufio.NewWriter(w)
+	for i, section := range names {
+		if section != "" {
+			if i > 0 {
+				bw.WriteString("\n")
+			}
+			fmt.Fprintf(bw, "[%s]\n", section)
+		}
+		for _, key := range sections[section] {
+			name := strings.TrimPrefix(key, section+".")
+			values := c.values[key].Values
+			if len(values) == 1 {
+				fmt.Fprintf(bw, "%s = %s\n", name, iniValue(values[0]))
+				continue
+			}
+			items := make([]string, len(values))
+			for j, v := range values {
+				items[j] = iniValue(v)
+			}
+			fmt.Fprintf(bw, "%s = [%s]\n", name, strings.Join(items, ", "))
+		}
+	}
+	return bw.Flush()
+}
+
+func iniValue(s string) string {
+	if isBareValue(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// isBareValue reports whether s is a boolean or an integer, written without
+// quotes.
+func isBareValue(s string) bool {
+	if s == "true" || s == "false" {
+		return true
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && strconv.FormatInt(i, 10) == s
+}
@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseINI(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string][]string
+		err   string
+	}{
+		{
+			name:  "top level",
+			input: "customer = first\n",
+			want:  map[string][]string{"customer": {"first"}},
+		},
+		{
+			name:  "sections and dotted keys",
+			input: "[queue.info]\ncustomer = \"first\"\nbase.v = true\n",
+			want: map[string][]string{
+				"queue.info.customer": {"first"},
+				"queue.info.base.v":   {"true"},
+			},
+		},
+		{
+			name:  "comments",
+			input: "# comment\n; comment\n[queue]\nv = true # comment\nname = a#b ; comment\n",
+			want: map[string][]string{
+				"queue.v":    {"true"},
+				"queue.name": {"a#b"},
+			},
+		},
+		{
+			name:  "quoted strings",
+			input: "a = \"tab\\there\"\nb = 'raw\\n'\nc = \"#not a comment\"\n",
+			want: map[string][]string{
+				"a": {"tab\there"},
+				"b": {`raw\n`},
+				"c": {"#not a comment"},
+			},
+		},
+		{
+			name:  "lists",
+			input: "a = [\"x\", 'y', 3]\nb = []\nc = [ \"z\" ] # one\n",
+			want: map[string][]string{
+				"a": {"x", "y", "3"},
+				"b": {},
+				"c": {"z"},
+			},
+		},
+		{
+			name:  "empty value",
+			input: "a =\n",
+			want:  map[string][]string{"a": {""}},
+		},
+		{name: "missing equal", input: "a\n", err: "line 1: expected key = value"},
+		{name: "unterminated section", input: "[a\n", err: "line 1: unterminated section"},
+		{name: "unterminated string", input: "\n\na = \"x\n", err: "line 3: unterminated string"},
+		{name: "unterminated list", input: "a = [1, 2\n", err: "line 1: unterminated list"},
+		{name: "trailing text", input: "a = \"x\" y\n", err: `line 1: unexpected "y" after value`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseConfig(strings.NewReader(tt.input), ConfigINI, "test")
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := configValues(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string][]string
+		err   string
+	}{
+		{
+			name:  "nested objects",
+			input: `{"queue": {"info": {"customer": "first", "base": {"v": true}}}}`,
+			want: map[string][]string{
+				"queue.info.customer": {"first"},
+				"queue.info.base.v":   {"true"},
+			},
+		},
+		{
+			name:  "numbers and lists",
+			input: `{"id": 12345678901234567, "rate": 1.5, "list": ["a", 2, false], "none": null}`,
+			want: map[string][]string{
+				"id":   {"12345678901234567"},
+				"rate": {"1.5"},
+				"list": {"a", "2", "false"},
+			},
+		},
+		{name: "nested list", input: `{"a": [[1]]}`, err: "a: lists can hold only strings, numbers and booleans"},
+		{name: "not an object", input: `[1]`, err: "json: cannot unmarshal array into Go value of type map[string]interface {}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseConfig(strings.NewReader(tt.input), ConfigJSON, "test")
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := configValues(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigOrigin(t *testing.T) {
+	c, err := ParseConfig(strings.NewReader("\n[queue]\nv = true\n"), ConfigINI, "app.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := c.Lookup("queue.v"); v.Origin != "app.ini:3" {
+		t.Errorf("got origin %q, want app.ini:3", v.Origin)
+	}
+}
+
+func TestConfigWrite(t *testing.T) {
+	c := NewConfig()
+	c.Set("top", "007")
+	c.Set("queue.v", "true")
+	c.Set("queue.info.customer", "first", "second \"quoted\"")
+	c.Set("queue.info.id", "12")
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: ConfigINI,
+			want: `top = "007"
+
+[queue]
+v = true
+
+[queue.info]
+customer = ["first", "second \"quoted\""]
+id = 12
+`,
+		},
+		{
+			format: ConfigJSON,
+			want: `{
+	"queue": {
+		"info": {
+			"customer": [
+				"first",
+				"second \"quoted\""
+			],
+			"id": 12
+		},
+		"v": true
+	},
+	"top": "007"
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := c.Write(&buf, tt.format); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), tt.want)
+			}
+			read, err := ParseConfig(&buf, tt.format, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := configValues(read), configValues(c); !reflect.DeepEqual(got, want) {
+				t.Errorf("read back %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestConfigWriteConflict(t *testing.T) {
+	c := NewConfig()
+	c.Set("queue", "x")
+	c.Set("queue.v", "true")
+	if err := c.Write(&bytes.Buffer{}, ConfigJSON); err == nil {
+		t.Error("expected an error for a key used as value and section")
+	}
+}
+
+func configValues(c *Config) map[string][]string {
+	values := make(map[string][]string)
+	for _, key := range c.Keys() {
+		v, _ := c.Lookup(key)
+		values[key] = v.Values
+	}
+	return values
+}
@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configNames are the names tried for a config file, in order
+var configNames = []string{"", ".json", ".toml", ".ini"}
+
+// DiscoverConfigFiles returns the config files of the CLI called name, from
+// the least to the most specific:
+//
+//     /etc/<name>/config
+//     $XDG_CONFIG_HOME/<name>/config, or $HOME/.config/<name>/config
+//     .<name> in the working directory or the closest of its parents
+//
+// Each of them can also have a .json, .toml or .ini extension.
+func DiscoverConfigFiles(name string) []string {
+	return discoverConfigFiles(name, "/etc", os.LookupEnv, os.Getwd)
+}
+
+func discoverConfigFiles(name string, systemDir string, lookupEnv func(string) (string, bool), getwd func() (string, error)) []string {
+	var files []string
+	if systemDir != "" {
+		if f := findConfigFile(filepath.Join(systemDir, name, "config")); f != "" {
+			files = append(files, f)
+		}
+	}
+	if f := findConfigFile(userConfigPath(name, lookupEnv)); f != "" {
+		files = append(files, f)
+	}
+	if f := findProjectConfig(name, getwd); f != "" {
+		files = append(files, f)
+	}
+	return files
+}
+
+// userConfigPath returns the path of the user config file without
+// extension, or an empty string when neither XDG_CONFIG_HOME nor HOME is
+// set.
+func userConfigPath(name string, lookupEnv func(string) (string, bool)) string {
+	dir, ok := lookupEnv("XDG_CONFIG_HOME")
+	if !ok || dir == "" {
+		home, ok := lookupEnv("HOME")
+		if !ok || home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, name, "config")
+}
+
+// findConfigFile returns the first existing file of path with one of the
+// configNames extensions.
+func findConfigFile(path string) string {
+	if path == "" {
+		return ""
+	}
+	for _, ext := range configNames {
+		if info, err := os.Stat(path + ext); err == nil && !info.IsDir() {
+			return path + ext
+		}
+	}
+	return ""
+}
+
+// findProjectConfig walks up from the working directory to find the .<name>
+// config file.
+func findProjectConfig(name string, getwd func() (string, error)) string {
+	if getwd == nil {
+		return ""
+	}
+	dir, err := getwd()
+	if err != nil || dir == "" {
+		return ""
+	}
+	for {
+		if f := findConfigFile(filepath.Join(dir, "."+name)); f != "" {
+			return f
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ConfigFile returns the config file changed by commands like config set or
+// profile use: the file given with the ConfigFlag, or the user config file.
+func (cli *CLI) ConfigFile() string {
+	if cli.configFile != "" {
+		return cli.configFile
+	}
+	if cli.root.Name == "" {
+		return ""
+	}
+	path := userConfigPath(cli.root.Name, cli.lookupEnv)
+	if path == "" {
+		return ""
+	}
+	if f := findConfigFile(path); f != "" {
+		return f
+	}
+	return path
+}
+
+// configFiles returns the config files read by Run
+func (cli *CLI) configFiles() []string {
+	if cli.configFile != "" {
+		return []string{cli.configFile}
+	}
+	var files []string
+	if cli.DiscoverConfig && cli.root.Name != "" {
+		files = discoverConfigFiles(cli.root.Name, cli.SystemConfigDir, cli.lookupEnv, cli.Getwd)
+	}
+	return append(files, cli.ConfigFiles...)
+}
+
+// loadConfig reads and merges the config files, missing files are skipped
+// unless given with the ConfigFlag.
+func (cli *CLI) loadConfig() error {
+	cli.config = NewConfig()
+	for _, path := range cli.configFiles() {
+		c, err := LoadConfig(path)
+		if os.IsNotExist(err) && cli.configFile == "" {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		cli.config.Merge(c)
+	}
+	return cli.applyProfile()
+}
+
+// usesConfig reports whether config files are read
+func (cli *CLI) usesConfig() bool {
+	return cli.DiscoverConfig || len(cli.ConfigFiles) > 0
+}
+
+// extractGlobalFlags removes the ConfigFlag and the ProfileFlag given before
+// the first command name from args, and stores their values. They are
+// recognized only when config files are used.
+func (cli *CLI) extractGlobalFlags(args []string) ([]string, error) {
+	cli.configFile, cli.profileFlag = "", ""
+	if !cli.usesConfig() || len(args) == 0 {
+		return args, nil
+	}
+	globals := map[string]*string{}
+	if cli.ConfigFlag != "" {
+		globals[cli.ConfigFlag] = &cli.configFile
+	}
+	if cli.ProfileFlag != "" {
+		globals[cli.ProfileFlag] = &cli.profileFlag
+	}
+	i := 1
+	for ; i < len(args); i++ {
+		arg := args[i]
+		name := strings.TrimLeft(arg, "-")
+		if dashes := len(arg) - len(name); dashes != 1 && dashes != 2 {
+			break
+		}
+		parts := strings.SplitN(name, "=", 2)
+		value, ok := globals[parts[0]]
+		if !ok {
+			break
+		}
+		if len(parts) == 2 {
+			*value = parts[1]
+			continue
+		}
+		if i+1 == len(args) {
+			return nil, fmt.Errorf("flag needs an argument: %s", arg)
+		}
+		i++
+		*value = args[i]
+	}
+	return append([]string{args[0]}, args[i:]...), nil
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiscoverConfigFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "discover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, path := range []string{
+		"etc/app/config.toml",
+		"home/.config/app/config",
+		"xdg/app/config.json",
+		"project/.app.ini",
+	} {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "project", "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		systemDir string
+		env       map[string]string
+		wd        string
+		want      []string
+	}{
+		{name: "none"},
+		{name: "system", systemDir: "etc", want: []string{"etc/app/config.toml"}},
+		{name: "home", env: map[string]string{"HOME": "home"}, want: []string{"home/.config/app/config"}},
+		{
+			name: "xdg first",
+			env:  map[string]string{"HOME": "home", "XDG_CONFIG_HOME": "xdg"},
+			want: []string{"xdg/app/config.json"},
+		},
+		{name: "project parent", wd: "project/a/b", want: []string{"project/.app.ini"}},
+		{name: "no project", wd: "home"},
+		{
+			name:      "all",
+			systemDir: "etc",
+			env:       map[string]string{"HOME": "home"},
+			wd:        "project",
+			want:      []string{"etc/app/config.toml", "home/.config/app/config", "project/.app.ini"},
+		},
+	}
+	abs := func(path string) string {
+		if path == "" {
+			return ""
+		}
+		return filepath.Join(dir, path)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookupEnv := func(key string) (string, bool) {
+				v, ok := tt.env[key]
+				return abs(v), ok
+			}
+			var getwd func() (string, error)
+			if tt.wd != "" {
+				getwd = func() (string, error) {
+					return abs(tt.wd), nil
+				}
+			}
+			got := discoverConfigFiles("app", abs(tt.systemDir), lookupEnv, getwd)
+			for i := range got {
+				got[i] = strings.TrimPrefix(got[i], dir+string(filepath.Separator))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractGlobalFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		config  string
+		profile string
+		err     string
+	}{
+		{name: "none", args: []string{"app", "queue", "-config", "x"}, want: []string{"app", "queue", "-config", "x"}},
+		{
+			name:    "before the command",
+			args:    []string{"app", "--config", "a.json", "-profile=staging", "queue", "--config", "x"},
+			want:    []string{"app", "queue", "--config", "x"},
+			config:  "a.json",
+			profile: "staging",
+		},
+		{name: "other flag first", args: []string{"app", "-v", "-config", "a"}, want: []string{"app", "-v", "-config", "a"}},
+		{name: "missing value", args: []string{"app", "--profile"}, err: "flag needs an argument: --profile"},
+		{name: "three dashes", args: []string{"app", "---config", "a"}, want: []string{"app", "---config", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := NewWithRoot(NewRoot("app", ""))
+			cli.ConfigFiles = []string{"app.json"}
+			got, err := cli.extractGlobalFlags(tt.args)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got args %q, want %q", got, tt.want)
+			}
+			if cli.configFile != tt.config || cli.profileFlag != tt.profile {
+				t.Errorf("got config %q and profile %q, want %q and %q", cli.configFile, cli.profileFlag, tt.config, tt.profile)
+			}
+		})
+	}
+	cli := NewWithRoot(NewRoot("app", ""))
+	args := []string{"app", "--config", "a.json", "queue"}
+	if got, _ := cli.extractGlobalFlags(args); !reflect.DeepEqual(got, args) {
+		t.Errorf("global flags taken without config files: %q", got)
+	}
+}
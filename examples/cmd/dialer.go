@@ -8,10 +8,6 @@ import (
 	"github.com/Ak-Army/cli/examples/cmd/dialer"
 )
 
-func init() {
-	cli.RootCommand().AddCommand("dialer", &Dialer{})
-}
-
 type Dialer struct{}
 
 func (d *Dialer) Help() string {
@@ -20,6 +16,15 @@ Usage: archiver dialer <command> [command options]
 `
 }
 
+func (d *Dialer) HelpTemplate() string {
+	return `{{.Help}}
+Commands:
+{{- range $name, $value := .SubCommands }}
+    {{$value.NameAligned}}    {{$value.Synopsis}}
+{{- end }}
+`
+}
+
 func (d *Dialer) Synopsis() string {
 	return "Interact with the dialer service"
 }
@@ -9,8 +9,9 @@ import (
 
 type Info struct {
 	base.Base `flag:"base"`
-	Customer  string `flag:"customer, print just the customer info"`
-	QueueId   int64  `flag:"queueId, print just one queue for a customer"`
+	Customer  string            `flag:"customer, print just the customer info"`
+	QueueId   *int64            `flag:"queueId, print just one queue for a customer"`
+	Labels    map[string]string `flag:"label, filter projects by label given as key=value"`
 }
 
 func (d *Info) Help() string {
@@ -23,5 +24,8 @@ func (d *Info) Synopsis() string {
 
 func (q *Info) Run(ctx context.Context) error {
 	fmt.Printf("project info %#v", q)
+	if q.QueueId != nil {
+		fmt.Printf(" queue %d", *q.QueueId)
+	}
 	return nil
 }
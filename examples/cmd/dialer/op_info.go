@@ -11,6 +11,7 @@ type OpInfo struct {
 	base.Base
 	Customer string `flag:"customer, print just the customer info"`
 	UserId   int64  `flag:"userId, print just one user for a customer"`
+	Format   string `flag:"format, the output format" choices:"csv,tsv,json" default:"csv"`
 }
 
 func (d *OpInfo) Help() string {
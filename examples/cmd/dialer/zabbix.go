@@ -9,6 +9,9 @@ import (
 
 type Zabbix struct {
 	base.Base
+	Token string   `flag:"token, zabbix api token" secret:"true"`
+	Host  string   `arg:"host"`
+	Items []string `arg:"item,optional,variadic"`
 }
 
 func (d *Zabbix) Help() string {
@@ -20,6 +23,6 @@ func (d *Zabbix) Synopsis() string {
 }
 
 func (d *Zabbix) Run(ctx context.Context) error {
-	fmt.Println("Zabbix")
+	fmt.Println("Zabbix", d.Host, d.Items, d.Token != "")
 	return nil
 }
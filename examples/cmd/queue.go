@@ -9,10 +9,6 @@ import (
 	"github.com/Ak-Army/cli/examples/cmd/queue"
 )
 
-func init() {
-	cli.RootCommand().AddCommand("queue", &Queue{})
-}
-
 type Queue struct {
 	base.Base
 }
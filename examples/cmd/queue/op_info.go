@@ -17,6 +17,10 @@ func (d *OpInfo) Help() string {
 	return ""
 }
 
+func (d *OpInfo) Aliases() []string {
+	return []string{"opinfo", "oi"}
+}
+
 func (d *OpInfo) Synopsis() string {
 	return "Get queue operator info"
 }
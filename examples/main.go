@@ -6,13 +6,13 @@ import (
 
 	"github.com/Ak-Army/cli"
 	"github.com/Ak-Army/cli/command"
-	_ "github.com/Ak-Army/cli/examples/cmd"
+	"github.com/Ak-Army/cli/examples/cmd"
 )
 
 func main() {
-	c := cli.New("archiver", "1.0.0")
-	cli.RootCommand().Authors = []string{"authors goes here"}
-	cli.RootCommand().Description = `Lorem Ipsum is simply dummy text of the printing and typesetting industry. 
+	root := cli.NewRoot("archiver", "1.0.0")
+	root.Authors = []string{"authors goes here"}
+	root.Description = `Lorem Ipsum is simply dummy text of the printing and typesetting industry. 
 Lorem Ipsum has been the industry's standard dummy text ever since the 1500s, 
 when an unknown printer took a galley of type and scrambled it to make a type 
 specimen book. It has survived not only five centuries, but also the leap into 
@@ -21,9 +21,17 @@ the 1960s with the release of Letraset sheets containing Lorem Ipsum passages,
 and more recently with desktop publishing software like Aldus PageMaker 
 including versions of Lorem Ipsum.`
 
-	cli.RootCommand().AddCommand("completion", &command.Completion{})
+	root.AddCommand("dialer", &cmd.Dialer{})
+	root.AddCommand("queue", &cmd.Queue{}, "q")
+	root.AddCommand("completion", command.New(root.Name))
+	root.AddCommand("profile", command.NewProfile())
+	root.AddCommand("config", command.NewConfig())
+
+	c := cli.NewWithRoot(root)
+	c.EnvPrefix = "ARCHIVER"
+	c.DiscoverConfig = true
 	c.SetDefault("dialer")
-	c.Run(context.Background(), os.Args)
+	os.Exit(c.Run(context.Background(), os.Args))
 }
 
 /*
@@ -67,28 +75,14 @@ func (c *ArchiveSubCommand) Run(ctx context.Context) {
 	fmt.Println("Archive na ez lefutott", c.Args(), c)
 }
 
-type CustomDate time.Time
-
-func (c *CustomDate) String() string {
-	return fmt.Sprint(*c)
-}
-func (c *CustomDate) Set(value string) error {
-	t, err := time.Parse("2006/01/02", value)
-	if err != nil {
-		return err
-	}
-	*c = CustomDate(t)
-	return nil
-}
-
 type Cdr struct {
 	*cli.Flagger
-	Format    string     `flag:"format, the output format: csv/tsv"`
-	Output    string     `flag:"output, the output format default STDOUT"`
-	Fields    string     `flag:"fields, filds wich will be exported"`
-	From      CustomDate `flag:"from, download voice files from (YYYY/MM/DD)"`
-	To        string     `flag:"to, download voice files until (YYYY/MM/DD)"`
-	ProjectID int        `flag:"projectId, download voice files only from the given project"`
+	Format    string    `flag:"format, the output format: csv/tsv"`
+	Output    string    `flag:"output, the output format default STDOUT"`
+	Fields    string    `flag:"fields, filds wich will be exported"`
+	From      time.Time `flag:"from, download voice files from" layout:"2006/01/02"`
+	To        string    `flag:"to, download voice files until (YYYY/MM/DD)"`
+	ProjectID int       `flag:"projectId, download voice files only from the given project"`
 }
 
 func (c *Cdr) Desc() string {
@@ -127,7 +121,7 @@ type Download struct {
 	Overwrite bool   `flag:"overwrite, overwrite the file if it already exists"`
 	From      string `flag:"from, download voice files from (YYYY/MM/DD)"`
 	To        string `flag:"to, download voice files until (YYYY/MM/DD)"`
-	ProjectId int    `flag:"projectId, download voice files only from the given project"`
+	ProjectId int    `flag:"projectId, download voice files only from the given project" required:"true"`
 }
 
 func (c *Download) Parse(args []string) error {
@@ -138,9 +132,6 @@ func (c *Download) Parse(args []string) error {
 	if ok, err := regexp.MatchString(dateRegex, c.From); err != nil || !ok {
 		return errors.New("from parameter is not a valid date")
 	}
-	if c.ProjectId == 0 {
-		return errors.New("projectId is required")
-	}
 
 	return nil
 }
@@ -170,10 +161,10 @@ func main() {
 		},
 		&Cdr{
 			Format: "csv",
-			From:   CustomDate(time.Now().AddDate(0, 0, -1)),
+			From:   time.Now().AddDate(0, 0, -1),
 			To:     time.Now().Format("2006/01/02"),
 		})
 	c.SetDefault("archive")
-	c.Run(context.Background(), os.Args)
+	os.Exit(c.Run(context.Background(), os.Args))
 }
 */
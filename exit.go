@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"errors"
+)
+
+// Exit statuses returned by CLI.Run
+const (
+	// ExitOK is returned when the command finished successfully.
+	ExitOK = 0
+	// ExitError is returned when the command failed.
+	ExitError = 1
+	// ExitUsage is returned when the command line could not be parsed.
+	ExitUsage = 2
+)
+
+type exitError struct {
+	err  error
+	code int
+}
+
+// WithExitCode wraps err so CLI.Run returns code when err is returned from a
+// Command.
+func WithExitCode(err error, code int) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{err: err, code: code}
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func (e *exitError) ExitCode() int {
+	return e.code
+}
+
+// usageError marks errors caused by a wrong command line.
+type usageError struct {
+	err error
+}
+
+func newUsageError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var ec ExitCoder
+	if errors.As(err, &ec) {
+		return err
+	}
+	return &usageError{err: err}
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+func (e *usageError) ExitCode() int {
+	return ExitUsage
+}
+
+// exitCode returns the exit status belonging to err.
+func exitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var ec ExitCoder
+	if errors.As(err, &ec) {
+		return ec.ExitCode()
+	}
+	return ExitError
+}
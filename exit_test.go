@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: ExitOK},
+		{name: "error", err: failed, want: ExitError},
+		{name: "with exit code", err: WithExitCode(failed, 3), want: 3},
+		{name: "wrapped exit code", err: fmt.Errorf("run: %w", WithExitCode(failed, 4)), want: 4},
+		{name: "usage", err: newUsageError(failed), want: ExitUsage},
+		{name: "usage keeps exit code", err: newUsageError(WithExitCode(failed, 5)), want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithExitCode(t *testing.T) {
+	if WithExitCode(nil, 3) != nil {
+		t.Error("expected nil for a nil error")
+	}
+	failed := errors.New("failed")
+	err := WithExitCode(failed, 3)
+	if !errors.Is(err, failed) {
+		t.Error("expected the error to be wrapped")
+	}
+	if err.Error() != "failed" {
+		t.Errorf("got message %q", err.Error())
+	}
+}
@@ -0,0 +1,401 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// pathCommand is a command with the names of the commands leading to it
+type pathCommand struct {
+	Command
+	path []string
+}
+
+// field is a struct field bound to a flag.
+type field struct {
+	name     string
+	short    string
+	value    reflect.Value
+	command  Command
+	path     []string
+	choices  []string
+	required bool
+	secret   bool
+	env      string
+	// origin tells where the value was set from: the command line, an
+	// environment variable or a config file.
+	origin string
+	// source is the struct field declaring the flag
+	source structField
+	// links are fields of other commands declaring the same flag, or the
+	// same struct field under another name, they receive the value of the
+	// field after parsing.
+	links []*field
+}
+
+// structField identifies a field of a struct type
+type structField struct {
+	typ   reflect.Type
+	index int
+}
+
+// sameField returns the field of another command than c declared by source
+func sameField(fields map[string]*field, source structField, c Command) *field {
+	for _, f := range fields {
+		if f.source == source && f.command != c {
+			return f
+		}
+	}
+	return nil
+}
+
+// restoreFields sets the flag and argument fields of c back to the values
+// they had when c was first run, the values parsed by a previous Run must not
+// become the defaults of the next one.
+func (cli *CLI) restoreFields(c Command, st reflect.Value) {
+	v := reflect.Indirect(st)
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return
+	}
+	if cli.initialValues == nil {
+		cli.initialValues = make(map[Command]reflect.Value)
+	}
+	initial, ok := cli.initialValues[c]
+	if !ok {
+		initial = reflect.New(v.Type()).Elem()
+		initial.Set(v)
+		cli.initialValues[c] = initial
+		return
+	}
+	restoreFields(v, initial)
+}
+
+// restoreFields sets the tagged fields of v to their value in initial, the
+// nested structs are walked like defineFlagSet does.
+func restoreFields(v reflect.Value, initial reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		typ := v.Type().Field(i)
+		val := v.Field(i)
+		flagTag := typ.Tag.Get("flag")
+		_, isArg := typ.Tag.Lookup("arg")
+		isStruct := typ.Type.Kind() == reflect.Struct && typ.Type != timeType &&
+			!reflect.PtrTo(typ.Type).Implements(flagValueType)
+		switch {
+		case isStruct && !isArg:
+			restoreFields(val, initial.Field(i))
+		case (isArg || flagTag != "" && !strings.HasPrefix(flagTag, "-")) && val.CanSet():
+			val.Set(initial.Field(i))
+		}
+	}
+}
+
+// usage returns the usage of the flag completed with its expected format,
+// choices and whether it is required.
+func (f *field) usage(usage string, tag reflect.StructTag) string {
+	if t := f.value.Type(); t == timeType || t.Kind() == reflect.Ptr && t.Elem() == timeType {
+		usage += fmt.Sprintf(" (format: %s)", timeLayoutName(timeLayout(tag.Get("layout"))))
+	}
+	if f.choices != nil {
+		usage += fmt.Sprintf(" (one of: %s)", strings.Join(f.choices, ", "))
+	}
+	if f.required {
+		usage += " (required)"
+	}
+	if f.secret {
+		usage += " (secret, @file or - reads it)"
+	}
+	if f.env != "" {
+		usage += " [$" + f.env + "]"
+	}
+	return usage
+}
+
+func (f *field) sync() {
+	for _, l := range f.links {
+		l.value.Set(f.value)
+	}
+}
+
+// syncFields copies the parsed values to the linked fields, and records the
+// flags set with their origin.
+func (cli *CLI) syncFields() {
+	for _, f := range cli.fields {
+		f.sync()
+	}
+	cli.visitSet(func(fl *flag.Flag) {
+		f := lookupField(cli.fields, fl.Name)
+		if f == nil {
+			return
+		}
+		if f.origin == "" {
+			f.origin = "flag"
+		}
+		cli.setFlags.mark(f.command, f.name, f.origin)
+		for _, l := range f.links {
+			cli.setFlags.mark(l.command, l.name, f.origin)
+		}
+	})
+}
+
+// visitSet calls fn for the flags set, it returns false when the flag set
+// does not implement Visit and can not tell which flags were set.
+func (cli *CLI) visitSet(fn func(*flag.Flag)) bool {
+	v, ok := cli.flagSet.(interface{ Visit(fn func(*flag.Flag)) })
+	if !ok {
+		return false
+	}
+	v.Visit(fn)
+	return true
+}
+
+// envName returns the environment variable name of a flag, like
+// PREFIX_QUEUE_INFO_CUSTOMER.
+func envName(prefix string, path []string, name string) string {
+	parts := append(append([]string{prefix}, path...), name)
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.Join(parts, "_"))
+}
+
+// checkVisit returns an error when required flags, environment variables or
+// config files are used with a flag set which can not tell the flags set.
+func (cli *CLI) checkVisit() error {
+	if _, ok := cli.flagSet.(interface{ Visit(fn func(*flag.Flag)) }); ok {
+		return nil
+	}
+	var feature string
+	for _, name := range cli.sortedFieldNames() {
+		f := cli.fields[name]
+		if f.required {
+			feature = "required flag " + cli.flagArg(name)
+			break
+		}
+		for _, l := range append([]*field{f}, f.links...) {
+			if l.env != "" {
+				feature = "environment variable " + l.env
+				break
+			}
+		}
+		if feature != "" {
+			break
+		}
+	}
+	if feature == "" && cli.usesConfig() {
+		feature = "config files"
+	}
+	if feature == "" {
+		return nil
+	}
+	return WithExitCode(fmt.Errorf("%s can not be used: the flag set %T does not implement Visit", feature, cli.flagSet), ExitError)
+}
+
+// setFromSources sets the flags not given on the command line from their
+// environment variables, or else from the config.
+func (cli *CLI) setFromSources() error {
+	set, ok := cli.setNames()
+	if !ok {
+		return nil
+	}
+	for _, name := range cli.sortedFieldNames() {
+		if set[name] {
+			continue
+		}
+		f := cli.fields[name]
+		fields := append([]*field{f}, f.links...)
+		ok, err := cli.setFromEnv(name, fields)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if err := cli.setFromConfig(name, fields); err != nil {
+			return err
+		}
+	}
+	cli.syncFields()
+	return nil
+}
+
+// setFromEnv sets the flag called name from the first environment variable
+// of fields found, it reports whether one was.
+func (cli *CLI) setFromEnv(name string, fields []*field) (bool, error) {
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		v, ok := cli.lookupEnv(f.env)
+		if !ok {
+			continue
+		}
+		if err := cli.flagSet.Set(name, v); err != nil {
+			return false, fmt.Errorf("invalid value %q for environment variable %s: %v", v, f.env, err)
+		}
+		fields[0].origin = "env " + f.env
+		return true, nil
+	}
+	return false, nil
+}
+
+// setFromConfig sets the flag called name from the config, keys of the
+// deepest commands declaring the flag come first.
+func (cli *CLI) setFromConfig(name string, fields []*field) error {
+	if cli.config == nil {
+		return nil
+	}
+	for i := len(fields) - 1; i >= 0; i-- {
+		key := configKey(fields[i].path, fields[i].name)
+		v, ok := cli.config.Lookup(key)
+		if !ok {
+			continue
+		}
+		for _, s := range v.Values {
+			if err := cli.flagSet.Set(name, s); err != nil {
+				return fmt.Errorf("invalid value %q for %s in %s: %v", s, key, v.Origin, err)
+			}
+		}
+		fields[0].origin = strings.TrimSpace("config " + v.Origin)
+		return nil
+	}
+	return nil
+}
+
+// configKey returns the config key of a flag, like queue.info.customer.
+func configKey(path []string, name string) string {
+	return strings.Join(append(append([]string(nil), path...), name), ".")
+}
+
+// lookupField returns the field of the flag called name, having name as
+// short alias, or linked to a field called name.
+func lookupField(fields map[string]*field, name string) *field {
+	if f, ok := fields[name]; ok {
+		return f
+	}
+	for _, f := range fields {
+		if f.short == name {
+			return f
+		}
+		for _, l := range f.links {
+			if l.name == name {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// setNames returns the names of the flags set, it returns false when the
+// flag set can not tell them.
+func (cli *CLI) setNames() (map[string]bool, bool) {
+	set := make(map[string]bool)
+	ok := cli.visitSet(func(fl *flag.Flag) {
+		if f := lookupField(cli.fields, fl.Name); f != nil {
+			set[f.name] = true
+		}
+	})
+	return set, ok
+}
+
+func (cli *CLI) sortedFieldNames() []string {
+	names := make([]string, 0, len(cli.fields))
+	for name := range cli.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkRequired returns an error listing the required flags not set
+func (cli *CLI) checkRequired() error {
+	set, ok := cli.setNames()
+	if !ok {
+		return nil
+	}
+	var missing []string
+	for name, f := range cli.fields {
+		if f.required && !set[name] {
+			missing = append(missing, cli.flagArg(name))
+		}
+	}
+	switch len(missing) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("missing required flag %s", missing[0])
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("missing required flags %s", strings.Join(missing, ", "))
+}
+
+// flagRegistry holds the origin of the flags set for each command
+type flagRegistry struct {
+	mu       sync.Mutex
+	commands map[Command]map[string]string
+}
+
+func newFlagRegistry() *flagRegistry {
+	return &flagRegistry{
+		commands: make(map[Command]map[string]string),
+	}
+}
+
+func (r *flagRegistry) mark(c Command, name string, origin string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.commands[c] == nil {
+		r.commands[c] = make(map[string]string)
+	}
+	r.commands[c][name] = origin
+}
+
+func (r *flagRegistry) origin(c Command, name string) string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.commands[c][name]
+}
+
+func (r *flagRegistry) names(c Command) []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.commands[c]))
+	for name := range r.commands[c] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsSet reports whether the flag called name of cmd was set by the last
+// Run, even if it was set to its default value.
+func (cli *CLI) IsSet(cmd Command, name string) bool {
+	return cli.Origin(cmd, name) != ""
+}
+
+// Origin returns where the flag called name of cmd was set from by the last
+// Run: "flag" for the command line, "env NAME" for an environment variable
+// or "config path:line" for a config file. It is empty when the flag kept
+// its default.
+func (cli *CLI) Origin(cmd Command, name string) string {
+	return cli.setFlags.origin(cmd, name)
+}
+
+// FlagsSet returns the sorted names of the flags of cmd set by the last Run.
+func (cli *CLI) FlagsSet(cmd Command) []string {
+	return cli.setFlags.names(cmd)
+}
@@ -0,0 +1,277 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// GNUFlagSet is a Flagger parsing GNU style command lines: long flags as
+// --name or --name=value, short flags as -n, -n value, -nvalue or bundled
+// like -abc, and -- terminating the flags. Flags with one letter names are
+// short flags. Parsing stops at the first non-flag argument.
+type GNUFlagSet struct {
+	flags  *flag.FlagSet
+	shorts map[string]string
+	args   []string
+	output io.Writer
+}
+
+// NewGNUFlagSet returns a new, empty GNUFlagSet
+func NewGNUFlagSet() *GNUFlagSet {
+	fs := &GNUFlagSet{
+		flags: &flag.FlagSet{
+			Usage: func() {},
+		},
+		shorts: make(map[string]string),
+	}
+	fs.flags.SetOutput(ioutil.Discard)
+	return fs
+}
+
+// Alias adds short as the one letter name of the flag called name
+func (g *GNUFlagSet) Alias(short string, name string) {
+	g.shorts[short] = name
+}
+
+func (g *GNUFlagSet) Parse(arguments []string) error {
+	g.args = arguments
+	for len(g.args) > 0 {
+		arg := g.args[0]
+		if len(arg) < 2 || arg[0] != '-' {
+			return nil
+		}
+		g.args = g.args[1:]
+		if arg == "--" {
+			return nil
+		}
+		var err error
+		if strings.HasPrefix(arg, "--") {
+			err = g.parseLong(arg[2:])
+		} else {
+			err = g.parseShorts(arg[1:])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GNUFlagSet) parseLong(arg string) error {
+	name, value := arg, ""
+	hasValue := false
+	if i := strings.Index(arg, "="); i >= 0 {
+		name, value, hasValue = arg[:i], arg[i+1:], true
+	}
+	f := g.flags.Lookup(name)
+	if f == nil {
+		if name == "help" || name == "h" {
+			return flag.ErrHelp
+		}
+		return fmt.Errorf("flag provided but not defined: --%s", name)
+	}
+	if !hasValue {
+		if isBoolFlag(f) {
+			value = "true"
+		} else if len(g.args) > 0 {
+			value, g.args = g.args[0], g.args[1:]
+		} else {
+			return fmt.Errorf("flag needs an argument: --%s", name)
+		}
+	}
+	return g.set(f, "--"+name, value)
+}
+
+func (g *GNUFlagSet) parseShorts(arg string) error {
+	for i := 0; i < len(arg); i++ {
+		short := arg[i : i+1]
+		f := g.lookupShort(short)
+		if f == nil {
+			if short == "h" {
+				return flag.ErrHelp
+			}
+			return fmt.Errorf("flag provided but not defined: -%s", short)
+		}
+		if isBoolFlag(f) {
+			if err := g.set(f, "-"+short, "true"); err != nil {
+				return err
+			}
+			continue
+		}
+		value := strings.TrimPrefix(arg[i+1:], "=")
+		if i+1 == len(arg) {
+			if len(g.args) == 0 {
+				return fmt.Errorf("flag needs an argument: -%s", short)
+			}
+			value, g.args = g.args[0], g.args[1:]
+		}
+		return g.set(f, "-"+short, value)
+	}
+	return nil
+}
+
+func (g *GNUFlagSet) lookupShort(short string) *flag.Flag {
+	if name, ok := g.shorts[short]; ok {
+		return g.flags.Lookup(name)
+	}
+	return g.flags.Lookup(short)
+}
+
+func (g *GNUFlagSet) set(f *flag.Flag, arg string, value string) error {
+	if err := g.flags.Set(f.Name, value); err != nil {
+		return fmt.Errorf("invalid value %q for flag %s: %v", value, arg, unwrapSetError(err))
+	}
+	return nil
+}
+
+// unwrapSetError returns the error of flag.Value.Set without the message
+// added by flag.FlagSet.Set
+func unwrapSetError(err error) error {
+	if u := errors.Unwrap(err); u != nil {
+		return u
+	}
+	return err
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func (g *GNUFlagSet) StringVar(p *string, name string, value string, usage string) {
+	g.flags.StringVar(p, name, value, usage)
+}
+
+func (g *GNUFlagSet) IntVar(p *int, name string, value int, usage string) {
+	g.flags.IntVar(p, name, value, usage)
+}
+
+func (g *GNUFlagSet) Int64Var(p *int64, name string, value int64, usage string) {
+	g.flags.Int64Var(p, name, value, usage)
+}
+
+func (g *GNUFlagSet) BoolVar(p *bool, name string, value bool, usage string) {
+	g.flags.BoolVar(p, name, value, usage)
+}
+
+func (g *GNUFlagSet) UintVar(p *uint, name string, value uint, usage string) {
+	g.flags.UintVar(p, name, value, usage)
+}
+
+func (g *GNUFlagSet) Uint64Var(p *uint64, name string, value uint64, usage string) {
+	g.flags.Uint64Var(p, name, value, usage)
+}
+
+func (g *GNUFlagSet) Float64Var(p *float64, name string, value float64, usage string) {
+	g.flags.Float64Var(p, name, value, usage)
+}
+
+func (g *GNUFlagSet) DurationVar(p *time.Duration, name string, value time.Duration, usage string) {
+	g.flags.DurationVar(p, name, value, usage)
+}
+
+func (g *GNUFlagSet) Var(value flag.Value, name string, usage string) {
+	g.flags.Var(value, name, usage)
+}
+
+func (g *GNUFlagSet) Set(name string, value string) error {
+	if long, ok := g.shorts[name]; ok {
+		name = long
+	}
+	return g.flags.Set(name, value)
+}
+
+func (g *GNUFlagSet) SetOutput(output io.Writer) {
+	g.output = output
+}
+
+func (g *GNUFlagSet) Visit(fn func(*flag.Flag)) {
+	g.flags.Visit(fn)
+}
+
+func (g *GNUFlagSet) VisitAll(fn func(*flag.Flag)) {
+	g.flags.VisitAll(fn)
+}
+
+func (g *GNUFlagSet) Args() []string {
+	return g.args
+}
+
+// PrintDefaults prints the flags with their short and long names, usage and
+// default values to the output.
+func (g *GNUFlagSet) PrintDefaults() {
+	output := g.output
+	if output == nil {
+		output = os.Stderr
+	}
+	longs := make(map[string]string, len(g.shorts))
+	for short, name := range g.shorts {
+		longs[name] = short
+	}
+	printDefaults(output, g.flags.VisitAll, func(name string) string {
+		switch short, ok := longs[name]; {
+		case ok:
+			return fmt.Sprintf("  -%s, --%s", short, name)
+		case len(name) == 1:
+			return "  -" + name
+		}
+		return "      --" + name
+	})
+}
+
+// printDefaults prints the flags visited by visitAll like
+// flag.PrintDefaults, label returns the names of a flag to print.
+// Flags whose label is empty are skipped.
+func printDefaults(output io.Writer, visitAll func(fn func(*flag.Flag)), label func(name string) string) {
+	visitAll(func(f *flag.Flag) {
+		l := label(f.Name)
+		if l == "" {
+			return
+		}
+		var b strings.Builder
+		b.WriteString(l)
+		name, usage := flag.UnquoteUsage(f)
+		if name != "" {
+			b.WriteString(" " + name)
+		}
+		if b.Len() <= 4 {
+			b.WriteString("\t")
+		} else {
+			b.WriteString("\n    \t")
+		}
+		b.WriteString(strings.Replace(usage, "\n", "\n    \t", -1))
+		if !isZeroValue(f) {
+			if name == "string" {
+				fmt.Fprintf(&b, " (default %q)", f.DefValue)
+			} else {
+				fmt.Fprintf(&b, " (default %s)", f.DefValue)
+			}
+		}
+		fmt.Fprintln(output, b.String())
+	})
+}
+
+// isZeroValue reports whether the default value of f is the zero value of
+// its type, like the flag package does.
+func isZeroValue(f *flag.Flag) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	typ := reflect.TypeOf(f.Value)
+	var z reflect.Value
+	if typ.Kind() == reflect.Ptr {
+		z = reflect.New(typ.Elem())
+	} else {
+		z = reflect.Zero(typ)
+	}
+	return f.DefValue == z.Interface().(flag.Value).String()
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"reflect"
+	"testing"
+)
+
+type gnuFlags struct {
+	all     bool
+	verbose bool
+	output  string
+	count   int
+}
+
+func newTestGNUFlagSet(f *gnuFlags) *GNUFlagSet {
+	g := NewGNUFlagSet()
+	g.BoolVar(&f.all, "all", false, "all")
+	g.Alias("a", "all")
+	g.BoolVar(&f.verbose, "verbose", false, "verbose")
+	g.Alias("v", "verbose")
+	g.StringVar(&f.output, "output", "", "output")
+	g.Alias("o", "output")
+	g.IntVar(&f.count, "count", 0, "count")
+	return g
+}
+
+func TestGNUFlagSetParse(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want gnuFlags
+		rest []string
+		err  string
+	}{
+		{name: "long", args: []string{"--verbose", "--output", "x"}, want: gnuFlags{verbose: true, output: "x"}, rest: []string{}},
+		{name: "long with value", args: []string{"--output=x=y", "--count=3"}, want: gnuFlags{output: "x=y", count: 3}, rest: []string{}},
+		{name: "short", args: []string{"-v", "-o", "x"}, want: gnuFlags{verbose: true, output: "x"}, rest: []string{}},
+		{name: "bundled", args: []string{"-av"}, want: gnuFlags{all: true, verbose: true}, rest: []string{}},
+		{name: "bundled with value", args: []string{"-avox"}, want: gnuFlags{all: true, verbose: true, output: "x"}, rest: []string{}},
+		{name: "short with equal", args: []string{"-o=x"}, want: gnuFlags{output: "x"}, rest: []string{}},
+		{name: "bundled with next value", args: []string{"-ao", "x", "arg"}, want: gnuFlags{all: true, output: "x"}, rest: []string{"arg"}},
+		{name: "terminator", args: []string{"-v", "--", "-a"}, want: gnuFlags{verbose: true}, rest: []string{"-a"}},
+		{name: "first argument stops", args: []string{"arg", "-v"}, rest: []string{"arg", "-v"}},
+		{name: "single dash is an argument", args: []string{"-"}, rest: []string{"-"}},
+		{name: "unknown long", args: []string{"--nope"}, err: "flag provided but not defined: --nope"},
+		{name: "unknown short", args: []string{"-x"}, err: "flag provided but not defined: -x"},
+		{name: "missing long value", args: []string{"--output"}, err: "flag needs an argument: --output"},
+		{name: "missing short value", args: []string{"-o"}, err: "flag needs an argument: -o"},
+		{name: "invalid value", args: []string{"--count", "x"}, err: `invalid value "x" for flag --count: parse error`},
+		{name: "help", args: []string{"--help"}, err: flag.ErrHelp.Error()},
+		{name: "short help", args: []string{"-h"}, err: flag.ErrHelp.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got gnuFlags
+			g := newTestGNUFlagSet(&got)
+			err := g.Parse(tt.args)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(g.Args(), tt.rest) {
+				t.Errorf("got args %q, want %q", g.Args(), tt.rest)
+			}
+		})
+	}
+}
+
+func TestGNUFlagSetHelpIsErrHelp(t *testing.T) {
+	g := newTestGNUFlagSet(&gnuFlags{})
+	if err := g.Parse([]string{"--help"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("got %v, want flag.ErrHelp", err)
+	}
+}
+
+func TestGNUFlagSetPrintDefaults(t *testing.T) {
+	var f gnuFlags
+	g := newTestGNUFlagSet(&f)
+	g.StringVar(&f.output, "x", "def", "one letter")
+	var buf bytes.Buffer
+	g.SetOutput(&buf)
+	g.PrintDefaults()
+	want := `  -a, --all
+    	all
+      --count int
+    	count
+  -o, --output string
+    	output
+  -v, --verbose
+    	verbose
+  -x string
+    	one letter (default "def")
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
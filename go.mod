@@ -1,8 +1,3 @@
 module github.com/Ak-Army/cli
 
 go 1.13
-
-require (
-	github.com/gobwas/glob v0.2.3 // indirect
-	github.com/sgreben/flagvar v0.0.0-20200103140453-77ac8eec431f
-)
@@ -25,13 +25,52 @@ type SubCommands interface {
 	SubCommands() map[string]Command
 }
 
+// Aliaser is an interface commands can implement to be callable by other
+// names too.
+type Aliaser interface {
+	// Aliases should return the alternative names of the command
+	Aliases() []string
+}
+
 type ParseHelper interface {
-	// Parse should help to validate flags, and add extra options
+	// Parse should help to validate flags, and add extra options. Parents
+	// are called with the arguments following their own flags, the name of
+	// the sub command first, before the flags of the sub command are parsed.
 	Parse([]string) error
 }
 
+// Defaulter is an interface commands can implement to set default values
+// computed at run time. It is called before the flags are registered, fields
+// left at their zero value receive the value of their default tag.
+type Defaulter interface {
+	SetDefaults()
+}
+
+// HelpTemplater is an interface commands can implement to use their own help
+// template instead of the one set on the CLI.
+type HelpTemplater interface {
+	HelpTemplate() string
+}
+
+// PreRunner is an interface commands can implement to run code before the
+// resolved command. It is called on every command of the resolved path,
+// starting with the outermost parent.
+type PreRunner interface {
+	PreRun(ctx context.Context) error
+}
+
+// PostRunner is an interface commands can implement to run code after the
+// resolved command. It is called in reverse order on every command of the
+// resolved path whose PreRun did not fail, with the error of the run so far.
+// The returned error replaces it.
+type PostRunner interface {
+	PostRun(ctx context.Context, err error) error
+}
+
 // Flagger is an interface satisfied by flag.FlagSet and other implementations
-// of flags.
+// of flags. Flaggers should also implement Visit(fn func(*flag.Flag)) like
+// flag.FlagSet, without it IsSet always reports false, and Run fails when
+// required flags, environment variables or config files are used.
 type Flagger interface {
 	Parse([]string) error
 	StringVar(p *string, name string, value string, usage string)
@@ -48,3 +87,9 @@ type Flagger interface {
 	VisitAll(fn func(*flag.Flag))
 	Args() []string
 }
+
+// ExitCoder is an interface errors returned by a Command can implement to
+// select the exit status returned by CLI.Run.
+type ExitCoder interface {
+	ExitCode() int
+}
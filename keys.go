@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// configFlag is a flag of a command a config key is bound to
+type configFlag struct {
+	flagSet *flag.FlagSet
+	name    string
+	secret  bool
+}
+
+// configFlags returns the flags of every command of the CLI by config key.
+// Flags are defined on copies of the commands, which are left untouched.
+func (cli *CLI) configFlags() (map[string]configFlag, error) {
+	flags := make(map[string]configFlag)
+	var walk func(parent SubCommands, path []string) error
+	walk = func(parent SubCommands, path []string) error {
+		for _, s := range subCommands(parent) {
+			p := append(append([]string(nil), path...), s.name)
+			st := reflect.ValueOf(s.command)
+			if st.Kind() != reflect.Ptr || st.IsNil() || st.Elem().Kind() != reflect.Struct {
+				continue
+			}
+			c := reflect.New(st.Elem().Type())
+			c.Elem().Set(st.Elem())
+			fs := flag.NewFlagSet(strings.Join(p, " "), flag.ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+			fields := make(map[string]*field)
+			pc := pathCommand{Command: s.command, path: p}
+			if err := cli.defineFlagSet(fs, fields, pc, c, ""); err != nil {
+				return fmt.Errorf("%s: %v", strings.Join(p, " "), err)
+			}
+			for name, f := range fields {
+				flags[configKey(p, name)] = configFlag{flagSet: fs, name: name, secret: f.secret}
+			}
+			if sub, ok := s.command.(SubCommands); ok {
+				if err := walk(sub, p); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return flags, walk(cli.root, nil)
+}
+
+// ConfigKeys returns the sorted config keys of the flags of every command
+func (cli *CLI) ConfigKeys() ([]string, error) {
+	flags, err := cli.configFlags()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(flags))
+	for key := range flags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// CheckConfig returns an error if key is not the config key of a flag, or
+// the values can not be set to the flag. Keys of profiles are checked like
+// the other keys, the profile key accepts any value.
+func (cli *CLI) CheckConfig(key string, values ...string) error {
+	if key == profileKey {
+		return nil
+	}
+	flags, err := cli.configFlags()
+	if err != nil {
+		return err
+	}
+	k := key
+	if strings.HasPrefix(k, profilesPrefix) {
+		parts := strings.SplitN(k, ".", 3)
+		if len(parts) < 3 || parts[1] == "" {
+			return fmt.Errorf("invalid profile key '%s'", key)
+		}
+		k = parts[2]
+	}
+	f, ok := flags[k]
+	if !ok {
+		candidates := make([]string, 0, len(flags))
+		for c := range flags {
+			candidates = append(candidates, c)
+		}
+		sort.Strings(candidates)
+		return fmt.Errorf("unknown config key '%s'%s", key, didYouMean(suggest(k, candidates)))
+	}
+	for _, v := range values {
+		if err := f.flagSet.Set(f.name, v); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %v", v, key, err)
+		}
+	}
+	return nil
+}
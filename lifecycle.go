@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"context"
+)
+
+// lifecycle returns a RunFunc which runs the last of the given commands,
+// surrounded by the PreRun and PostRun hooks of every command.
+func (cli *CLI) lifecycle(commands []Command) RunFunc {
+	return func(ctx context.Context) error {
+		var err error
+		ran := 0
+		for _, c := range commands {
+			if p, ok := c.(PreRunner); ok {
+				if err = p.PreRun(ctx); err != nil {
+					break
+				}
+			}
+			ran++
+		}
+		if err == nil && len(commands) > 0 {
+			err = commands[len(commands)-1].Run(ctx)
+		}
+		for i := ran - 1; i >= 0; i-- {
+			if p, ok := commands[i].(PostRunner); ok {
+				err = p.PostRun(ctx, err)
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type hooked struct {
+	name    string
+	calls   *[]string
+	preErr  error
+	runErr  error
+	postErr func(err error) error
+}
+
+func (h *hooked) Help() string     { return h.name }
+func (h *hooked) Synopsis() string { return h.name }
+
+func (h *hooked) Run(ctx context.Context) error {
+	*h.calls = append(*h.calls, "run "+h.name)
+	return h.runErr
+}
+
+func (h *hooked) PreRun(ctx context.Context) error {
+	*h.calls = append(*h.calls, "pre "+h.name)
+	return h.preErr
+}
+
+func (h *hooked) PostRun(ctx context.Context, err error) error {
+	*h.calls = append(*h.calls, "post "+h.name)
+	if h.postErr != nil {
+		return h.postErr(err)
+	}
+	return err
+}
+
+func TestLifecycle(t *testing.T) {
+	failed := errors.New("failed")
+	replaced := errors.New("replaced")
+	tests := []struct {
+		name  string
+		setup func(parent, child *hooked)
+		calls string
+		err   error
+	}{
+		{
+			name:  "order",
+			setup: func(parent, child *hooked) {},
+			calls: "pre parent, pre child, run child, post child, post parent",
+		},
+		{
+			name: "run error",
+			setup: func(parent, child *hooked) {
+				child.runErr = failed
+			},
+			calls: "pre parent, pre child, run child, post child, post parent",
+			err:   failed,
+		},
+		{
+			name: "pre run error",
+			setup: func(parent, child *hooked) {
+				child.preErr = failed
+			},
+			calls: "pre parent, pre child, post parent",
+			err:   failed,
+		},
+		{
+			name: "parent pre run error",
+			setup: func(parent, child *hooked) {
+				parent.preErr = failed
+			},
+			calls: "pre parent",
+			err:   failed,
+		},
+		{
+			name: "post run replaces error",
+			setup: func(parent, child *hooked) {
+				child.runErr = failed
+				child.postErr = func(err error) error {
+					if err != failed {
+						t.Errorf("got error %v in post run, want %v", err, failed)
+					}
+					return replaced
+				}
+			},
+			calls: "pre parent, pre child, run child, post child, post parent",
+			err:   replaced,
+		},
+		{
+			name: "post run clears error",
+			setup: func(parent, child *hooked) {
+				child.runErr = failed
+				parent.postErr = func(err error) error {
+					return nil
+				}
+			},
+			calls: "pre parent, pre child, run child, post child, post parent",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			parent := &hooked{name: "parent", calls: &calls}
+			child := &hooked{name: "child", calls: &calls}
+			tt.setup(parent, child)
+			err := (&CLI{}).lifecycle([]Command{parent, child})(context.Background())
+			if err != tt.err {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+			if got := strings.Join(calls, ", "); got != tt.calls {
+				t.Errorf("got calls %q, want %q", got, tt.calls)
+			}
+		})
+	}
+}
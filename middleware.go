@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+)
+
+// RunFunc is the signature of Command.Run
+type RunFunc func(ctx context.Context) error
+
+// Middleware wraps the run of the resolved command, it should call next to
+// continue the chain.
+type Middleware func(next RunFunc) RunFunc
+
+// Use adds middlewares wrapping the run of every resolved command. The first
+// added middleware is the outermost one.
+func (cli *CLI) Use(middlewares ...Middleware) {
+	cli.middlewares = append(cli.middlewares, middlewares...)
+}
+
+func (cli *CLI) chain(run RunFunc) RunFunc {
+	for i := len(cli.middlewares) - 1; i >= 0; i-- {
+		run = cli.middlewares[i](run)
+	}
+	return run
+}
+
+type invocationKey struct{}
+
+type invocation struct {
+	cli     *CLI
+	path    []string
+	command Command
+	args    []string
+}
+
+func withInvocation(ctx context.Context, inv *invocation) context.Context {
+	return context.WithValue(ctx, invocationKey{}, inv)
+}
+
+func invocationFrom(ctx context.Context) *invocation {
+	if inv, ok := ctx.Value(invocationKey{}).(*invocation); ok {
+		return inv
+	}
+	return &invocation{}
+}
+
+// CommandPath returns the names of the commands resolved from the command
+// line, without the name of the root command.
+func CommandPath(ctx context.Context) []string {
+	path := invocationFrom(ctx).path
+	return append([]string(nil), path...)
+}
+
+// CommandFromContext returns the resolved command, with its flags already
+// parsed.
+func CommandFromContext(ctx context.Context) Command {
+	return invocationFrom(ctx).command
+}
+
+// RedactedArgs returns the arguments given to CLI.Run with the values of
+// secret flags masked, safe to be logged.
+func RedactedArgs(ctx context.Context) []string {
+	return append([]string(nil), invocationFrom(ctx).args...)
+}
+
+// FromContext returns the CLI running the command
+func FromContext(ctx context.Context) *CLI {
+	return invocationFrom(ctx).cli
+}
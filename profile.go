@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// profileKey is the config key of the current profile
+	profileKey = "profile"
+	// profilesPrefix starts the config keys of the profiles, like
+	// profiles.staging.queue.info.customer.
+	profilesPrefix = "profiles."
+)
+
+// Profiles returns the sorted names of the profiles in the config
+func (c *Config) Profiles() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for key := range c.values {
+		if !strings.HasPrefix(key, profilesPrefix) {
+			continue
+		}
+		name := strings.SplitN(strings.TrimPrefix(key, profilesPrefix), ".", 2)[0]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the values of the profile called name, with keys relative
+// to the profile. It returns nil if the profile does not exist.
+func (c *Config) Profile(name string) *Config {
+	prefix := profilesPrefix + name + "."
+	var p *Config
+	for key, v := range c.values {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if p == nil {
+			p = NewConfig()
+		}
+		p.values[strings.TrimPrefix(key, prefix)] = v
+	}
+	return p
+}
+
+// Profile returns the name of the profile used by the last Run
+func (cli *CLI) Profile() string {
+	return cli.profile
+}
+
+// ProfileEnv returns the name of the environment variable selecting the
+// profile, like ARCHIVER_PROFILE.
+func (cli *CLI) ProfileEnv() string {
+	return envName(cli.root.Name, nil, profileKey)
+}
+
+// applyProfile merges the selected profile into the config. The profile is
+// selected by the ProfileFlag, the ProfileEnv environment variable, or the
+// profile key of the config. An unknown profile is an error when given with
+// the ProfileFlag, otherwise it is reported and ignored, to let the profile
+// and config commands fix it.
+func (cli *CLI) applyProfile() error {
+	cli.profile = cli.profileFlag
+	source := "--" + cli.ProfileFlag
+	if cli.profile == "" && cli.root.Name != "" {
+		cli.profile, _ = cli.lookupEnv(cli.ProfileEnv())
+		source = cli.ProfileEnv()
+	}
+	if v, ok := cli.config.Lookup(profileKey); cli.profile == "" && ok && len(v.Values) > 0 {
+		cli.profile = v.Values[0]
+		source = strings.TrimSpace("config " + v.Origin)
+	}
+	if cli.profile == "" {
+		return nil
+	}
+	p := cli.config.Profile(cli.profile)
+	if p != nil {
+		cli.config.Merge(p)
+		return nil
+	}
+	if cli.profileFlag != "" {
+		return fmt.Errorf("unknown profile %q", cli.profile)
+	}
+	if !cli.completing {
+		fmt.Fprintf(cli.ErrorWriter, "warning: unknown profile %q selected by %s is ignored\n", cli.profile, source)
+	}
+	cli.profile = ""
+	return nil
+}
@@ -0,0 +1,22 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigProfiles(t *testing.T) {
+	c := NewConfig()
+	c.Set("profile", "prod")
+	c.Set("profiles.prod.queue.info.customer", "p")
+	c.Set("profiles.staging.queue.v", "true")
+	if got, want := c.Profiles(), []string{"prod", "staging"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got profiles %v, want %v", got, want)
+	}
+	if got, want := configValues(c.Profile("prod")), map[string][]string{"queue.info.customer": {"p"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got profile %v, want %v", got, want)
+	}
+	if c.Profile("missing") != nil {
+		t.Error("expected no profile")
+	}
+}
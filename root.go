@@ -11,12 +11,22 @@ type Root struct {
 	Description string
 	Authors     []string
 	subCommands map[string]Command
+	aliases     map[string][]string
 }
 
 var defaultRoot = &Root{
 	subCommands: make(map[string]Command),
 }
 
+// NewRoot returns a new Root command without sub commands
+func NewRoot(name string, version string) *Root {
+	return &Root{
+		Name:        name,
+		Version:     version,
+		subCommands: make(map[string]Command),
+	}
+}
+
 // RootCommand returns the default Root command
 func RootCommand() *Root {
 	return defaultRoot
@@ -27,15 +37,43 @@ func SetRoot(root *Root) {
 	defaultRoot = root
 }
 
-// AddCommand add a main command
-func (r *Root) AddCommand(name string, command Command) bool {
-	if _, ok := r.subCommands[name]; ok {
-		return false
+// AddCommand add a main command, which can be called by its aliases too. It
+// returns false when the name or one of the aliases, those returned by an
+// Aliaser included, is already used by another command.
+func (r *Root) AddCommand(name string, command Command, aliases ...string) bool {
+	if r.subCommands == nil {
+		r.subCommands = make(map[string]Command)
+	}
+	names := append([]string{name}, aliases...)
+	if a, ok := command.(Aliaser); ok {
+		names = append(names, a.Aliases()...)
+	}
+	used := make(map[string]bool)
+	for _, s := range subCommands(r) {
+		for _, n := range s.names() {
+			used[n] = true
+		}
+	}
+	for _, n := range names {
+		if used[n] {
+			return false
+		}
+		used[n] = true
 	}
 	r.subCommands[name] = command
+	if len(aliases) > 0 {
+		if r.aliases == nil {
+			r.aliases = make(map[string][]string)
+		}
+		r.aliases[name] = aliases
+	}
 	return true
 }
 
+func (r *Root) aliasesOf(name string) []string {
+	return r.aliases[name]
+}
+
 // SubCommands return the main commands
 func (r *Root) SubCommands() map[string]Command {
 	return r.subCommands
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// secretMask replaces the values of secret flags in dumps and logs
+const secretMask = "******"
+
+// secretValue hides the value of a secret flag from help, it can be read
+// from a file given as @path, or from the input given as -.
+type secretValue struct {
+	flag.Value
+	input io.Reader
+}
+
+func (s *secretValue) String() string {
+	return ""
+}
+
+func (s *secretValue) Set(value string) error {
+	v, err := readSecret(value, s.input)
+	if err != nil {
+		return err
+	}
+	return s.Value.Set(v)
+}
+
+// readSecret returns the content of the file when value is @path, of input
+// when value is -, and value otherwise. Trailing new lines are removed.
+func readSecret(value string, input io.Reader) (string, error) {
+	var data []byte
+	var err error
+	switch {
+	case value == "-":
+		if input == nil {
+			input = os.Stdin
+		}
+		data, err = ioutil.ReadAll(input)
+	case strings.HasPrefix(value, "@"):
+		data, err = ioutil.ReadFile(value[1:])
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// redactArgs returns a copy of args with the values of secret flags masked.
+// The flags are read like the flag set parses them, with GNUFlagSet a single
+// dash starts bundled short flags like -vt value or -tvalue.
+func (cli *CLI) redactArgs(args []string) []string {
+	out := append([]string(nil), args...)
+	_, gnu := cli.flagSet.(*GNUFlagSet)
+	for i := 1; i < len(out); i++ {
+		arg := out[i]
+		if arg == "--" {
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			continue
+		}
+		var next, secret bool
+		if gnu && arg[1] != '-' {
+			out[i], next, secret = cli.redactShorts(arg)
+		} else {
+			out[i], next, secret = cli.redactFlag(arg)
+		}
+		if next && i+1 < len(out) {
+			i++
+			if secret {
+				out[i] = secretMask
+			}
+		}
+	}
+	return out
+}
+
+// redactFlag masks the value of arg given as -name=value or --name=value
+// when the flag is secret. It reports whether the value of the flag is the
+// next argument, and whether it is secret.
+func (cli *CLI) redactFlag(arg string) (string, bool, bool) {
+	parts := strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)
+	f, isBool := cli.lookupFlag(parts[0])
+	switch {
+	case f == nil:
+		return arg, false, false
+	case len(parts) == 2 && f.secret:
+		return arg[:len(arg)-len(parts[1])] + secretMask, false, false
+	case len(parts) == 2:
+		return arg, false, false
+	}
+	return arg, !isBool, f.secret
+}
+
+// redactShorts masks the value of the secret flag in arg given as bundled
+// short flags, like -vtvalue. It reports whether the value of the last flag
+// is the next argument, and whether it is secret.
+func (cli *CLI) redactShorts(arg string) (string, bool, bool) {
+	for i := 1; i < len(arg); i++ {
+		f, isBool := cli.lookupFlag(arg[i : i+1])
+		switch {
+		case f == nil:
+			return arg, false, false
+		case isBool:
+			continue
+		case i+1 == len(arg):
+			return arg, true, f.secret
+		case f.secret:
+			prefix := arg[:i+1]
+			if arg[i+1] == '=' {
+				prefix += "="
+			}
+			return prefix + secretMask, false, false
+		}
+		return arg, false, false
+	}
+	return arg, false, false
+}
+
+// lookupFlag returns the field of the flag called name, and whether the flag
+// takes no value like bool flags.
+func (cli *CLI) lookupFlag(name string) (*field, bool) {
+	f := lookupField(cli.fields, name)
+	if f == nil {
+		return nil, false
+	}
+	isBool := false
+	cli.flagSet.VisitAll(func(fl *flag.Flag) {
+		if fl.Name == f.name {
+			isBool = isBoolFlag(fl)
+		}
+	})
+	return f, isBool
+}
+
+// RedactConfig returns a copy of config with the values of secret flags
+// masked, keys of profiles included.
+func (cli *CLI) RedactConfig(config *Config) (*Config, error) {
+	flags, err := cli.configFlags()
+	if err != nil {
+		return nil, err
+	}
+	redacted := NewConfig()
+	for key, v := range config.values {
+		k := key
+		if parts := strings.SplitN(key, ".", 3); len(parts) == 3 && parts[0]+"." == profilesPrefix {
+			k = parts[2]
+		}
+		if f, ok := flags[k]; ok && f.secret {
+			v = &ConfigValue{Values: []string{secretMask}, Origin: v.Origin}
+		}
+		redacted.values[key] = v
+	}
+	return redacted, nil
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type secrets struct {
+	Token   string `flag:"token|t, token" secret:"true"`
+	Verbose bool   `flag:"verbose|v, verbose"`
+	Name    string `flag:"name|n, name"`
+}
+
+func (s *secrets) Help() string                  { return "Secrets" }
+func (s *secrets) Synopsis() string              { return "Secrets" }
+func (s *secrets) Run(ctx context.Context) error { return nil }
+
+func TestRedactArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		gnu  bool
+		args string
+		want string
+	}{
+		{name: "value", args: "-token s -t s", want: "-token ****** -t ******"},
+		{name: "equal", args: "-token=s --t=s", want: "-token=****** --t=******"},
+		{name: "bool first", args: "-v -token s", want: "-v -token ******"},
+		{name: "value of another flag", args: "-name -token s", want: "-name -token s"},
+		{name: "after terminator", args: "-- -token s", want: "-- -token s"},
+		{name: "gnu long", gnu: true, args: "--token s --token=s", want: "--token ****** --token=******"},
+		{name: "gnu attached", gnu: true, args: "-ts -t=s", want: "-t****** -t=******"},
+		{name: "gnu bundled", gnu: true, args: "-vt s -vts", want: "-vt ****** -vt******"},
+		{name: "gnu bundled value", gnu: true, args: "-vnt s", want: "-vnt s"},
+		{name: "gnu value of another flag", gnu: true, args: "-n -t s", want: "-n -t s"},
+		{name: "gnu unknown", gnu: true, args: "-xt s", want: "-xt s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli := NewWithRoot(NewRoot("app", ""))
+			cli.flagSet = &flag.FlagSet{}
+			if tt.gnu {
+				cli.flagSet = NewGNUFlagSet()
+			}
+			cli.fields = make(map[string]*field)
+			c := &secrets{}
+			if err := cli.defineFlagSet(cli.flagSet, cli.fields, pathCommand{Command: c}, reflect.ValueOf(c), ""); err != nil {
+				t.Fatal(err)
+			}
+			args := append([]string{"app", "cmd"}, strings.Fields(tt.args)...)
+			got := strings.Join(cli.redactArgs(args)[2:], " ")
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var defaultSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+
+// runWithSignals runs fn with a context canceled by the first received
+// signal. It returns the received signal if any and the error of fn.
+// A second signal, or an expired grace period, terminates the process.
+func (cli *CLI) runWithSignals(ctx context.Context, fn func(ctx context.Context) error) (os.Signal, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	signals := cli.Signals
+	if signals == nil {
+		signals = defaultSignals
+	}
+	sigCh := make(chan os.Signal, 2)
+	if len(signals) > 0 {
+		signal.Notify(sigCh, signals...)
+		defer signal.Stop(sigCh)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	var sig os.Signal
+	select {
+	case err := <-done:
+		return nil, err
+	case sig = <-sigCh:
+		cancel()
+	}
+
+	var grace <-chan time.Time
+	if cli.GracePeriod > 0 {
+		t := time.NewTimer(cli.GracePeriod)
+		defer t.Stop()
+		grace = t.C
+	}
+	select {
+	case err := <-done:
+		return sig, err
+	case sig = <-sigCh:
+	case <-grace:
+	}
+	cli.exit(signalExitCode(sig))
+	return sig, ctx.Err()
+}
+
+func (cli *CLI) exit(code int) {
+	if cli.Exit != nil {
+		cli.Exit(code)
+		return
+	}
+	os.Exit(code)
+}
+
+// signalExitCode returns the conventional 128+n exit status of a signal.
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return ExitError
+}
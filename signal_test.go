@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunWithSignals(t *testing.T) {
+	c := &CLI{Signals: []os.Signal{syscall.SIGUSR1}}
+	sig, err := c.runWithSignals(context.Background(), func(ctx context.Context) error {
+		syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if sig != syscall.SIGUSR1 {
+		t.Errorf("got signal %v, want %v", sig, syscall.SIGUSR1)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+}
+
+func TestRunWithSignalsNoSignal(t *testing.T) {
+	c := &CLI{Signals: []os.Signal{syscall.SIGUSR1}}
+	failed := errors.New("failed")
+	sig, err := c.runWithSignals(context.Background(), func(ctx context.Context) error {
+		return failed
+	})
+	if sig != nil || err != failed {
+		t.Errorf("got %v, %v, want no signal and the error of the run", sig, err)
+	}
+}
+
+func TestRunWithSignalsGracePeriod(t *testing.T) {
+	exited := make(chan int, 1)
+	c := &CLI{
+		Signals:     []os.Signal{syscall.SIGUSR1},
+		GracePeriod: 10 * time.Millisecond,
+		Exit: func(code int) {
+			exited <- code
+		},
+	}
+	block := make(chan struct{})
+	defer close(block)
+	c.runWithSignals(context.Background(), func(ctx context.Context) error {
+		syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+		<-block
+		return nil
+	})
+	select {
+	case code := <-exited:
+		if want := 128 + int(syscall.SIGUSR1); code != want {
+			t.Errorf("got exit code %d, want %d", code, want)
+		}
+	default:
+		t.Error("expected a forced exit after the grace period")
+	}
+}
+
+func TestSignalExitCode(t *testing.T) {
+	if got := signalExitCode(syscall.SIGINT); got != 130 {
+		t.Errorf("got %d for SIGINT, want 130", got)
+	}
+	if got := signalExitCode(syscall.SIGTERM); got != 143 {
+		t.Errorf("got %d for SIGTERM, want 143", got)
+	}
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const undefinedFlagPrefix = "flag provided but not defined: "
+
+// suggest returns the candidates close to name, the closest first.
+func suggest(name string, candidates []string) []string {
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	for _, c := range candidates {
+		d := levenshtein(name, c)
+		longest := len(c)
+		if len(name) > longest {
+			longest = len(name)
+		}
+		if d <= 2 && d < longest || strings.HasPrefix(c, name) && name != "" {
+			matches = append(matches, match{name: c, distance: d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.name
+	}
+	return names
+}
+
+// didYouMean formats the suggestions for an error message
+func didYouMean(suggestions []string) string {
+	switch len(suggestions) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf(", did you mean '%s'?", suggestions[0])
+	}
+	quoted := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		quoted[i] = "'" + s + "'"
+	}
+	return ", did you mean one of " + strings.Join(quoted, ", ") + "?"
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// unknownCommandError returns the error of a not found sub command
+func unknownCommandError(name string, candidates []string) error {
+	return fmt.Errorf("unknown command '%s'%s", name, didYouMean(suggest(name, candidates)))
+}
+
+// parseFlags parses args with the flag set of the CLI, and adds suggestions
+// to the error of an unknown flag.
+func (cli *CLI) parseFlags(args []string) error {
+	err := cli.flagSet.Parse(args)
+	if err == nil || !strings.HasPrefix(err.Error(), undefinedFlagPrefix) {
+		return err
+	}
+	arg := strings.TrimPrefix(err.Error(), undefinedFlagPrefix)
+	var names []string
+	cli.flagSet.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	suggestions := suggest(strings.TrimLeft(arg, "-"), names)
+	for i, s := range suggestions {
+		suggestions[i] = cli.flagArg(s)
+	}
+	return errors.New(fmt.Sprintf("unknown flag '%s'%s", arg, didYouMean(suggestions)))
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"queue", "queue", 0},
+		{"queu", "queue", 1},
+		{"qeueu", "queue", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"dialer", "queue", "quit", "completion"}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"queu", []string{"queue", "quit"}},
+		{"qu", []string{"quit", "queue"}},
+		{"dailer", []string{"dialer"}},
+		{"x", []string{}},
+		{"", []string{}},
+	}
+	for _, tt := range tests {
+		if got := suggest(tt.name, candidates); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("suggest(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	tests := []struct {
+		suggestions []string
+		want        string
+	}{
+		{nil, ""},
+		{[]string{"queue"}, ", did you mean 'queue'?"},
+		{[]string{"queue", "quit"}, ", did you mean one of 'queue', 'quit'?"},
+	}
+	for _, tt := range tests {
+		if got := didYouMean(tt.suggestions); got != tt.want {
+			t.Errorf("didYouMean(%q) = %q, want %q", tt.suggestions, got, tt.want)
+		}
+	}
+}
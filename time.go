@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var timeLayouts = map[string]string{
+	"RFC3339":  time.RFC3339,
+	"RFC1123":  time.RFC1123,
+	"RFC822":   time.RFC822,
+	"Kitchen":  time.Kitchen,
+	"DateTime": "2006-01-02 15:04:05",
+	"DateOnly": "2006-01-02",
+	"TimeOnly": "15:04:05",
+}
+
+// timeLayout returns the layout set in the layout tag, it can be the name
+// of a layout of the time package. Defaults to RFC3339.
+func timeLayout(tag string) string {
+	if tag == "" {
+		return time.RFC3339
+	}
+	if layout, ok := timeLayouts[tag]; ok {
+		return layout
+	}
+	return tag
+}
+
+// timeLayoutName returns the name of layout to show in help
+func timeLayoutName(layout string) string {
+	for name, l := range timeLayouts {
+		if l == layout && strings.HasPrefix(name, "RFC") {
+			return name
+		}
+	}
+	return layout
+}
+
+// timeValue is a flag.Value setting a time.Time field. Besides values in
+// the given layout it accepts now, today, yesterday, tomorrow and relative
+// times like -1d, +2w or -90m.
+type timeValue struct {
+	value  reflect.Value
+	layout string
+}
+
+func (t *timeValue) String() string {
+	if t == nil || !t.value.IsValid() {
+		return ""
+	}
+	v := t.value.Interface().(time.Time)
+	if v.IsZero() {
+		return ""
+	}
+	return v.Format(t.layout)
+}
+
+func (t *timeValue) Set(value string) error {
+	v, err := parseTime(value, t.layout, time.Now())
+	if err != nil {
+		return err
+	}
+	t.value.Set(reflect.ValueOf(v))
+	return nil
+}
+
+func parseTime(value string, layout string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch value {
+	case "now":
+		return now, nil
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+	if len(value) > 1 && (value[0] == '-' || value[0] == '+') {
+		return parseRelativeTime(value, now)
+	}
+	v, err := time.ParseInLocation(layout, value, time.Local)
+	if err != nil {
+		return v, fmt.Errorf("expected format %s", timeLayoutName(layout))
+	}
+	return v, nil
+}
+
+// parseRelativeTime parses value as an offset from now, days and weeks are
+// supported besides the units of time.ParseDuration.
+func parseRelativeTime(value string, now time.Time) (time.Time, error) {
+	switch value[len(value)-1] {
+	case 'd', 'w':
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil {
+			return now, errParse
+		}
+		if value[len(value)-1] == 'w' {
+			n *= 7
+		}
+		return now.AddDate(0, 0, n), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return now, errParse
+	}
+	return now.Add(d), nil
+}
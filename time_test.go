@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2020, 3, 15, 10, 30, 0, 0, time.Local)
+	today := time.Date(2020, 3, 15, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		value  string
+		layout string
+		want   time.Time
+		err    string
+	}{
+		{value: "now", want: now},
+		{value: "today", want: today},
+		{value: "yesterday", want: today.AddDate(0, 0, -1)},
+		{value: "tomorrow", want: today.AddDate(0, 0, 1)},
+		{value: "-1d", want: now.AddDate(0, 0, -1)},
+		{value: "+2w", want: now.AddDate(0, 0, 14)},
+		{value: "-90m", want: now.Add(-90 * time.Minute)},
+		{value: "+1h30m", want: now.Add(90 * time.Minute)},
+		{value: "2020-01-02", layout: "2006-01-02", want: time.Date(2020, 1, 2, 0, 0, 0, 0, time.Local)},
+		{value: "2020-01-02T03:04:05Z", want: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{value: "-xd", err: "parse error"},
+		{value: "-1y", err: "parse error"},
+		{value: "02/01/2020", layout: "2006-01-02", err: "expected format 2006-01-02"},
+		{value: "2020-01-02", err: "expected format RFC3339"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			layout := tt.layout
+			if layout == "" {
+				layout = time.RFC3339
+			}
+			got, err := parseTime(tt.value, layout, now)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeLayout(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{tag: "", want: time.RFC3339},
+		{tag: "DateOnly", want: "2006-01-02"},
+		{tag: "02/01/2006", want: "02/01/2006"},
+	}
+	for _, tt := range tests {
+		if got := timeLayout(tt.tag); got != tt.want {
+			t.Errorf("timeLayout(%q) = %q, want %q", tt.tag, got, tt.want)
+		}
+	}
+}
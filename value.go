@@ -0,0 +1,340 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	flagValueType = reflect.TypeOf((*flag.Value)(nil)).Elem()
+	durationType  = reflect.TypeOf(time.Duration(0))
+
+	errParse = errors.New("parse error")
+	errRange = errors.New("value out of range")
+)
+
+// isScalar reports whether values of typ can be parsed by parseScalar
+func isScalar(typ reflect.Type) bool {
+	if reflect.PtrTo(typ).Implements(flagValueType) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// parseScalar returns s parsed as a value of typ
+func parseScalar(typ reflect.Type, s string) (reflect.Value, error) {
+	v := reflect.New(typ).Elem()
+	if fv, ok := v.Addr().Interface().(flag.Value); ok {
+		return v, fv.Set(s)
+	}
+	if typ == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return v, errParse
+		}
+		v.SetInt(int64(d))
+		return v, nil
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, errParse
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, typ.Bits())
+		if err != nil {
+			return v, numError(err)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, typ.Bits())
+		if err != nil {
+			return v, numError(err)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, typ.Bits())
+		if err != nil {
+			return v, numError(err)
+		}
+		v.SetFloat(f)
+	default:
+		return v, fmt.Errorf("unsupported type %s", typ)
+	}
+	return v, nil
+}
+
+// formatScalar returns v formatted the way parseScalar accepts it
+func formatScalar(v reflect.Value) string {
+	if v.CanAddr() {
+		if fv, ok := v.Addr().Interface().(flag.Value); ok {
+			return fv.String()
+		}
+	}
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func numError(err error) error {
+	if ne, ok := err.(*strconv.NumError); ok && ne.Err == strconv.ErrRange {
+		return errRange
+	}
+	return errParse
+}
+
+// sliceValue is a flag.Value appending to a slice field. The first Set
+// replaces the default value, and values are split by sep if it is set.
+type sliceValue struct {
+	value reflect.Value
+	sep   string
+	set   bool
+}
+
+func (s *sliceValue) String() string {
+	if s == nil || !s.value.IsValid() {
+		return ""
+	}
+	sep := s.sep
+	if sep == "" {
+		sep = ","
+	}
+	parts := make([]string, s.value.Len())
+	for i := range parts {
+		parts[i] = formatScalar(s.value.Index(i))
+	}
+	return strings.Join(parts, sep)
+}
+
+func (s *sliceValue) Set(value string) error {
+	if !s.set {
+		s.value.Set(reflect.MakeSlice(s.value.Type(), 0, 0))
+		s.set = true
+	}
+	values := []string{value}
+	if s.sep != "" {
+		values = strings.Split(value, s.sep)
+	}
+	for _, v := range values {
+		e, err := parseScalar(s.value.Type().Elem(), v)
+		if err != nil {
+			return err
+		}
+		s.value.Set(reflect.Append(s.value, e))
+	}
+	return nil
+}
+
+func (s *sliceValue) IsBoolFlag() bool {
+	return s.value.Type().Elem().Kind() == reflect.Bool
+}
+
+// mapValue is a flag.Value adding key=value pairs to a map field. The first
+// Set replaces the default value, and pairs are split by sep if it is set.
+type mapValue struct {
+	value reflect.Value
+	sep   string
+	set   bool
+}
+
+func (m *mapValue) String() string {
+	if m == nil || !m.value.IsValid() {
+		return ""
+	}
+	sep := m.sep
+	if sep == "" {
+		sep = ","
+	}
+	pairs := make([]string, 0, m.value.Len())
+	iter := m.value.MapRange()
+	for iter.Next() {
+		pairs = append(pairs, formatScalar(iter.Key())+"="+formatScalar(iter.Value()))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, sep)
+}
+
+func (m *mapValue) Set(value string) error {
+	if !m.set || m.value.IsNil() {
+		m.value.Set(reflect.MakeMap(m.value.Type()))
+		m.set = true
+	}
+	pairs := []string{value}
+	if m.sep != "" {
+		pairs = strings.Split(value, m.sep)
+	}
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("expected key=value, got %q", pair)
+		}
+		k, err := parseScalar(m.value.Type().Key(), kv[0])
+		if err != nil {
+			return fmt.Errorf("key %q: %s", kv[0], err)
+		}
+		v, err := parseScalar(m.value.Type().Elem(), kv[1])
+		if err != nil {
+			return fmt.Errorf("value of %q: %s", kv[0], err)
+		}
+		m.value.SetMapIndex(k, v)
+	}
+	return nil
+}
+
+// ptrValue is a flag.Value setting a pointer field, it stays nil unless the
+// flag is given.
+type ptrValue struct {
+	value  reflect.Value
+	layout string
+}
+
+func (p *ptrValue) String() string {
+	if p == nil || !p.value.IsValid() || p.value.IsNil() {
+		return ""
+	}
+	if t, ok := p.value.Interface().(*time.Time); ok {
+		return t.Format(p.layout)
+	}
+	return formatScalar(p.value.Elem())
+}
+
+func (p *ptrValue) Set(value string) error {
+	typ := p.value.Type().Elem()
+	var v reflect.Value
+	if typ == timeType {
+		t, err := parseTime(value, p.layout, time.Now())
+		if err != nil {
+			return err
+		}
+		v = reflect.ValueOf(t)
+	} else {
+		var err error
+		if v, err = parseScalar(typ, value); err != nil {
+			return err
+		}
+	}
+	ptr := reflect.New(typ)
+	ptr.Elem().Set(v)
+	p.value.Set(ptr)
+	return nil
+}
+
+func (p *ptrValue) IsBoolFlag() bool {
+	return p.value.Type().Elem().Kind() == reflect.Bool
+}
+
+// newValue returns a flag.Value setting val, or nil if the type of val is
+// not supported.
+func newValue(val reflect.Value, tag reflect.StructTag) flag.Value {
+	typ := val.Type()
+	if fv, ok := val.Addr().Interface().(flag.Value); ok {
+		return fv
+	}
+	switch {
+	case typ == timeType:
+		return &timeValue{value: val, layout: timeLayout(tag.Get("layout"))}
+	case isScalar(typ):
+		return &scalarValue{value: val}
+	case typ.Kind() == reflect.Slice && isScalar(typ.Elem()):
+		return &sliceValue{value: val, sep: tag.Get("sep")}
+	case typ.Kind() == reflect.Ptr && (isScalar(typ.Elem()) || typ.Elem() == timeType):
+		return &ptrValue{value: val, layout: timeLayout(tag.Get("layout"))}
+	case typ.Kind() == reflect.Map && isScalar(typ.Key()) && isScalar(typ.Elem()):
+		return &mapValue{value: val, sep: tag.Get("sep")}
+	}
+	return nil
+}
+
+// scalarValue is a flag.Value setting a field of a type parseScalar supports.
+type scalarValue struct {
+	value reflect.Value
+}
+
+func (s *scalarValue) String() string {
+	if s == nil || !s.value.IsValid() {
+		return ""
+	}
+	return formatScalar(s.value)
+}
+
+func (s *scalarValue) Set(value string) error {
+	v, err := parseScalar(s.value.Type(), value)
+	if err != nil {
+		return err
+	}
+	s.value.Set(v)
+	return nil
+}
+
+func (s *scalarValue) IsBoolFlag() bool {
+	return s.value.Kind() == reflect.Bool
+}
+
+// choiceValue is a flag.Value accepting only the given choices, values are
+// split by sep if it is set.
+type choiceValue struct {
+	flag.Value
+	choices []string
+	sep     string
+}
+
+func (c *choiceValue) String() string {
+	if c == nil || c.Value == nil {
+		return ""
+	}
+	return c.Value.String()
+}
+
+func (c *choiceValue) Set(value string) error {
+	values := []string{value}
+	if c.sep != "" {
+		values = strings.Split(value, c.sep)
+	}
+	for _, v := range values {
+		if !c.valid(v) {
+			return fmt.Errorf("must be one of: %s", strings.Join(c.choices, ", "))
+		}
+	}
+	return c.Value.Set(value)
+}
+
+func (c *choiceValue) valid(value string) bool {
+	for _, choice := range c.choices {
+		if choice == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *choiceValue) IsBoolFlag() bool {
+	b, ok := c.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// setDefault sets val to def parsed like a value of the flag
+func setDefault(val reflect.Value, tag reflect.StructTag, def string) error {
+	value := newValue(val, tag)
+	if value == nil {
+		return fmt.Errorf("unsupported type %s", val.Type())
+	}
+	return value.Set(def)
+}